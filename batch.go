@@ -0,0 +1,228 @@
+package gocfg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/Gardego5/gocfg/utils"
+)
+
+// BatchLoader is implemented by loaders that can load many fields in one
+// call, such as remote backends fetching several secrets per round trip.
+// Load hands a BatchLoader every field that is ready to load at once, in
+// place of calling its Load method for each.
+type BatchLoader interface {
+	Loader
+
+	// LoadBatch loads each request like Load would, returning one error per
+	// request, in the same order, with nil for the requests it loaded.
+	LoadBatch(ctx context.Context, requests []FieldRequest) []error
+}
+
+// FieldRequest is a field for a BatchLoader to load
+type FieldRequest struct {
+	Field reflect.StructField   // The field being loaded
+	Path  []reflect.StructField // As returned by FieldPath
+	Value reflect.Value         // The field's value, to set
+	Tag   string                // The resolved tag
+
+	ctx context.Context
+}
+
+// Context returns the context Load would be called with for the field, for
+// FieldPath and MarkCached
+func (r FieldRequest) Context() context.Context {
+	if r.ctx == nil {
+		return context.Background()
+	}
+	return r.ctx
+}
+
+// WithContext returns a copy of r whose Context is ctx
+func (r FieldRequest) WithContext(ctx context.Context) FieldRequest {
+	r.ctx = ctx
+	return r
+}
+
+// fieldLoad is the progress of loading a field through its sources
+type fieldLoad struct {
+	n        *node
+	value    reflect.Value
+	next     int   // Index of the next source to try
+	missing  error // First missing required error from a source
+	supplied bool
+	origin   Origin // Of the value, once supplied
+}
+
+// done reports whether the field needs no more sources tried
+func (fl *fieldLoad) done() bool {
+	return fl.supplied || fl.next >= len(fl.n.sources)
+}
+
+// result records the outcome of trying the field's next source. Sources
+// reporting utils.ErrNotFound or utils.ErrMissingRequired are skipped in favor
+// of the next one, other errors fail the field.
+func (fl *fieldLoad) result(err error) error {
+	fl.next++
+	switch {
+	case err == nil:
+		fl.supplied = true
+	case errors.Is(err, utils.ErrMissingRequired):
+		if fl.missing == nil {
+			fl.missing = err
+		}
+	case errors.Is(err, utils.ErrNotFound):
+	default:
+		return fmt.Errorf("error loading %s: %w", fl.n.fieldName, err)
+	}
+	return nil
+}
+
+// loadLayer loads fields that don't depend on each other. Each field's
+// sources are tried in order, and in every round the fields whose next source
+// is the same BatchLoader are loaded with a single call to it.
+func loadLayer(ctx context.Context, layer []*node, state *loadState) error {
+	configValue := state.configValue
+
+	var loads []*fieldLoad
+fields:
+	for _, n := range layer {
+		for _, cond := range n.conditions {
+			holds, err := cond.holds(configValue)
+			if err != nil {
+				return fmt.Errorf("error evaluating condition for %s: %w", n.fieldName, err)
+			}
+			if !holds {
+				continue fields // Conditional fields are skipped
+			}
+		}
+
+		fl := &fieldLoad{n: n, value: configValue.FieldByIndex(n.fieldIndex)}
+		if n.expr != nil {
+			if err := evalExpr(n.expr, configValue, fl.value); err != nil {
+				return fmt.Errorf("error computing %s: %w", n.fieldName, err)
+			}
+			fl.supplied, fl.origin = true, Origin{Tag: exprTagName}
+		}
+		if fl.done() {
+			// Computed, or without sources
+			if err := fl.finish(state); err != nil {
+				return err
+			}
+			continue
+		}
+		loads = append(loads, fl)
+	}
+
+	for {
+		var batchers []BatchLoader
+		batches := make(map[BatchLoader][]*fieldLoad)
+		requests := make(map[BatchLoader][]FieldRequest)
+
+		for _, fl := range loads {
+			if fl.done() {
+				continue // Finished in an earlier round
+			}
+			field := fl.n.fieldPath[len(fl.n.fieldPath)-1]
+
+			for !fl.done() {
+				src := fl.n.sources[fl.next]
+
+				// Resolve references in the tag
+				resolvedTag, err := src.resolve(configValue)
+				if err != nil {
+					return fmt.Errorf("error resolving tag for %s: %w", fl.n.fieldName, err)
+				}
+
+				fl.origin = Origin{Tag: src.loader.GocfgLoaderName()}
+				fieldCtx := context.WithValue(ctx, fieldPathKey{}, fl.n.fieldPath)
+				fieldCtx = context.WithValue(fieldCtx, originKey{}, &fl.origin)
+
+				// Leave the field to the batch of its next source
+				if batcher, ok := src.loader.(BatchLoader); ok {
+					if _, exists := batches[batcher]; !exists {
+						batchers = append(batchers, batcher)
+					}
+					batches[batcher] = append(batches[batcher], fl)
+					requests[batcher] = append(requests[batcher], FieldRequest{
+						Field: field, Path: fl.n.fieldPath, Value: fl.value, Tag: resolvedTag,
+						ctx: fieldCtx,
+					})
+					break
+				}
+
+				// Load the value using the appropriate loader
+				if err := fl.result(src.loader.Load(fieldCtx, field, fl.value, resolvedTag)); err != nil {
+					return err
+				}
+			}
+
+			if fl.done() {
+				if err := fl.finish(state); err != nil {
+					return err
+				}
+			}
+		}
+
+		if len(batchers) == 0 {
+			break
+		}
+
+		for _, batcher := range batchers {
+			errs := batcher.LoadBatch(ctx, requests[batcher])
+			if len(errs) != len(requests[batcher]) {
+				return fmt.Errorf("loader %s returned %d results for %d fields",
+					batcher.GocfgLoaderName(), len(errs), len(requests[batcher]))
+			}
+
+			for i, fl := range batches[batcher] {
+				if err := fl.result(errs[i]); err != nil {
+					return err
+				}
+				if fl.done() {
+					if err := fl.finish(state); err != nil {
+						return err
+					}
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// finish falls back to the field's default when no source had a value, then
+// checks the field against its required tag. Fields are resolved once
+// finished, so loaders can look them up.
+func (fl *fieldLoad) finish(state *loadState) error {
+	n, configValue := fl.n, state.configValue
+	n.resolved = true
+
+	if !fl.supplied && n.hasDefault {
+		resolvedDefault, err := resolveTag(n.defaultTag, configValue)
+		if err != nil {
+			return fmt.Errorf("error resolving default for %s: %w", n.fieldName, err)
+		}
+		if err := utils.SetFieldValue(fl.value, utils.UnescapeTag(resolvedDefault)); err != nil {
+			return fmt.Errorf("error setting default for %s: %w", n.fieldName, err)
+		}
+		fl.supplied, fl.origin = true, Origin{Tag: defaultTagName}
+	}
+
+	if fl.supplied {
+		state.provenance[n.fieldName] = fl.origin
+	}
+
+	switch {
+	case !fl.supplied && fl.missing != nil:
+		return fmt.Errorf("error loading %s: %w", n.fieldName, fl.missing)
+	case n.required == requireSupplied && !fl.supplied:
+		return fmt.Errorf("%w: %s was not supplied by any loader", utils.ErrMissingRequired, n.fieldName)
+	case n.required == requireNonZero && (!fl.supplied || fl.value.IsZero()):
+		return fmt.Errorf("%w: %s must not be empty", utils.ErrMissingRequired, n.fieldName)
+	}
+
+	return nil
+}
@@ -3,11 +3,18 @@ package gocfg_test
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log/slog"
+	"reflect"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	. "github.com/Gardego5/gocfg"
 	"github.com/Gardego5/gocfg/loaders/env"
+	"github.com/Gardego5/gocfg/utils"
 )
 
 func TestLoadEnv(t *testing.T) {
@@ -158,8 +165,6 @@ func TestLoadEnv(t *testing.T) {
 	})
 
 	t.Run("Allows escaping characters using '\"'", func(t *testing.T) {
-		t.Skip("escaping test not implemented yet")
-
 		const expected = "test"
 
 		t.Setenv("@@SPECIAL", expected)
@@ -173,6 +178,806 @@ func TestLoadEnv(t *testing.T) {
 	})
 }
 
+func TestTagSyntax(t *testing.T) {
+	t.Run("Passes quoted literals through", func(t *testing.T) {
+		t.Setenv("HOST", "db")
+		if env, err := Load[struct {
+			Separator string `env:"SEPARATOR='a||b'"`
+			Address   string `env:"ADDRESS=@Host || ':5432?'"`
+			Host      string `env:"HOST"`
+		}](context.Background(), env.New()); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		} else if env.Separator != "a||b" {
+			t.Fatalf("expected Separator=a||b, got %s", env.Separator)
+		} else if env.Address != "db:5432?" {
+			t.Fatalf("expected Address=db:5432?, got %s", env.Address)
+		}
+	})
+
+	t.Run("Escapes special characters", func(t *testing.T) {
+		t.Setenv("OPTIONAL?", "yes")
+		if env, err := Load[struct {
+			Value string `env:"OPTIONAL\"?"`
+			Pipe  string `env:"PIPE=\"|\"|"`
+		}](context.Background(), env.New()); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		} else if env.Value != "yes" {
+			t.Fatalf("expected Value=yes, got %s", env.Value)
+		} else if env.Pipe != "||" {
+			t.Fatalf("expected Pipe=||, got %s", env.Pipe)
+		}
+	})
+
+	t.Run("Passes apostrophes through", func(t *testing.T) {
+		if env, err := Load[struct {
+			Name string `env:"NAME=it's"`
+			Note string `env:"NOTE=don't 'quote' me"`
+		}](context.Background(), env.New()); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		} else if env.Name != "it's" {
+			t.Fatalf("expected Name=it's, got %s", env.Name)
+		} else if env.Note != "don't quote me" {
+			t.Fatalf("expected Note=don't quote me, got %s", env.Note)
+		}
+	})
+
+	t.Run("Passes a single | through", func(t *testing.T) {
+		if env, err := Load[struct {
+			Value string `env:"VALUE=a|b"`
+		}](context.Background(), env.New()); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		} else if env.Value != "a|b" {
+			t.Fatalf("expected Value=a|b, got %s", env.Value)
+		}
+	})
+
+	t.Run("Passes tags unescaped to loaders not reading escapes", func(t *testing.T) {
+		t.Setenv("ARN", "arn:aws:ssm")
+		loader := &mapBatchLoader{values: map[string]string{"arn:aws:ssm|v1?": "yes"}}
+		if env, err := Load[struct {
+			ARN   string `env:"ARN"`
+			Value string `batch:"@ARN || '|v1?'"`
+		}](context.Background(), env.New(), loader); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		} else if env.Value != "yes" {
+			t.Fatalf("expected Value=yes, got %s", env.Value)
+		}
+	})
+
+	t.Run("Reads referenced values literally", func(t *testing.T) {
+		t.Setenv("NAME", "VAR?")
+		if _, err := Load[struct {
+			Name  string `env:"NAME"`
+			Value string `env:"@Name"`
+		}](context.Background(), env.New()); !errors.Is(err, utils.ErrMissingRequired) {
+			t.Fatalf("expected VAR? to be read as a required name, got %v", err)
+		}
+	})
+
+	t.Run("Reports the position of syntax errors", func(t *testing.T) {
+		for tag, offset := range map[string]int{
+			`VAR='unterminated`: 4,
+			`||VAR`:             0,
+			`VAR||`:             3,
+			`VAR=@`:             4,
+			`VAR="`:             4,
+		} {
+			_, err := Load[struct {
+				Value string
+			}](context.Background(), staticTagLoader(tag))
+
+			var syntaxErr *SyntaxError
+			if !errors.As(err, &syntaxErr) {
+				t.Errorf("%s: expected syntax error, got %v", tag, err)
+			} else if syntaxErr.Offset != offset {
+				t.Errorf("%s: expected offset %d, got %d", tag, offset, syntaxErr.Offset)
+			}
+		}
+	})
+}
+
+func TestReferenceFormatting(t *testing.T) {
+	t.Setenv("PORT", "80")
+	t.Setenv("DEBUG", "true")
+	t.Setenv("TIMEOUT", "1m30s")
+	t.Setenv("ENV", " staging ")
+	t.Setenv("LEVEL", "warn")
+
+	if env, err := Load[struct {
+		Port    int           `env:"PORT"`
+		Debug   bool          `env:"DEBUG"`
+		Timeout time.Duration `env:"TIMEOUT"`
+		Env     string        `env:"ENV"`
+		Level   slog.Level    `env:"LEVEL"`
+
+		Plain   string `env:"PLAIN=@Port/@Debug/@Timeout/@Level"`
+		Padded  string `env:"PADDED=@Port:%05d"`
+		Hex     string `env:"HEX=@Port:%x"`
+		Upper   string `env:"UPPER=@Env|trim|upper"`
+		Escaped string `env:"ESCAPED=@Level|upper:@Port"`
+	}](context.Background(), env.New()); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	} else {
+		for name, actual := range map[string][2]string{
+			"Plain":   {"80/true/1m30s/WARN", env.Plain},
+			"Padded":  {"00080", env.Padded},
+			"Hex":     {"50", env.Hex},
+			"Upper":   {"STAGING", env.Upper},
+			"Escaped": {"WARN:80", env.Escaped},
+		} {
+			if actual[0] != actual[1] {
+				t.Errorf("expected %s=%s, got %s", name, actual[0], actual[1])
+			}
+		}
+	}
+
+	t.Run("Formats the values of pointer fields", func(t *testing.T) {
+		if env, err := Load[struct {
+			Shard   *int `pointer:"7"`
+			Missing *int `pointer:"nil"`
+
+			Name  string `env:"NAME=shard-@Shard:%03d"`
+			Other string `env:"OTHER=[@Missing:%d]"`
+		}](context.Background(), env.New(), pointerLoader{}); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		} else if env.Name != "shard-007" || env.Other != "[]" {
+			t.Fatalf("expected Name=shard-007 and Other=[], got %s and %s", env.Name, env.Other)
+		}
+	})
+
+	t.Run("Rejects unknown filters and verbs", func(t *testing.T) {
+		for tag, offset := range map[string]int{
+			`VAR=@Value|shout`: 11,
+			`VAR=@Value:%`:     11,
+		} {
+			_, err := Load[struct {
+				Value string
+			}](context.Background(), staticTagLoader(tag))
+
+			var syntaxErr *SyntaxError
+			if !errors.As(err, &syntaxErr) {
+				t.Errorf("%s: expected syntax error, got %v", tag, err)
+			} else if syntaxErr.Offset != offset {
+				t.Errorf("%s: expected offset %d, got %d", tag, offset, syntaxErr.Offset)
+			}
+		}
+	})
+}
+
+func TestLoadConditional(t *testing.T) {
+	type Config struct {
+		TLSEnabled bool   `env:"TLS_ENABLED=false"`
+		TLSCert    string `env:"TLS_CERT" when:"@TLSEnabled"`
+
+		CacheBackend string `env:"CACHE_BACKEND=memory"`
+		RedisURL     string `env:"REDIS_URL" when:"@CacheBackend|lower==redis" required:"nonzero"`
+		CacheSize    int    `env:"CACHE_SIZE=64" when:"@CacheBackend|lower!=redis"`
+
+		Debug struct {
+			Addr string `env:"DEBUG_ADDR"`
+		} `when:"!@TLSEnabled"`
+	}
+
+	t.Run("Skips fields whose condition is false", func(t *testing.T) {
+		t.Setenv("TLS_ENABLED", "true")
+		t.Setenv("TLS_CERT", "/etc/tls/cert.pem")
+		t.Setenv("CACHE_SIZE", "128")
+
+		if env, err := Load[Config](context.Background(), env.New()); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		} else if env.TLSCert != "/etc/tls/cert.pem" || env.RedisURL != "" || env.CacheSize != 128 || env.Debug.Addr != "" {
+			t.Fatalf("unexpected config %+v", env)
+		}
+	})
+
+	t.Run("Loads fields whose condition is true", func(t *testing.T) {
+		t.Setenv("CACHE_BACKEND", "Redis")
+		t.Setenv("REDIS_URL", "redis://localhost")
+		t.Setenv("DEBUG_ADDR", ":6060")
+
+		if env, err := Load[Config](context.Background(), env.New()); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		} else if env.TLSCert != "" || env.RedisURL != "redis://localhost" || env.CacheSize != 0 || env.Debug.Addr != ":6060" {
+			t.Fatalf("unexpected config %+v", env)
+		}
+	})
+
+	t.Run("Enforces required only when the condition is true", func(t *testing.T) {
+		t.Setenv("CACHE_BACKEND", "redis")
+		t.Setenv("DEBUG_ADDR", ":6060")
+
+		if _, err := Load[Config](context.Background(), env.New()); !errors.Is(err, utils.ErrMissingRequired) {
+			t.Fatalf("expected missing required error, got %v", err)
+		}
+	})
+
+	t.Run("Compares with references", func(t *testing.T) {
+		t.Setenv("PRIMARY", "us-east-1")
+		t.Setenv("REGION", "us-east-1")
+		if env, err := Load[struct {
+			Primary  string `env:"PRIMARY"`
+			Region   string `env:"REGION"`
+			IsLeader bool   `env:"IS_LEADER=true" when:"@Region==@Primary"`
+		}](context.Background(), env.New()); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		} else if !env.IsLeader {
+			t.Fatal("expected IsLeader=true")
+		}
+	})
+
+	t.Run("Rejects invalid conditions", func(t *testing.T) {
+		var syntaxErr *SyntaxError
+		if _, err := Load[struct {
+			A string `env:"A?"`
+			B string `env:"B?" when:"A==b"`
+		}](context.Background(), env.New()); !errors.As(err, &syntaxErr) {
+			t.Fatalf("expected syntax error for missing @, got %v", err)
+		}
+		if _, err := Load[struct {
+			A string `env:"A?"`
+			B string `env:"B?" when:"!@A==b"`
+		}](context.Background(), env.New()); !errors.As(err, &syntaxErr) {
+			t.Fatalf("expected syntax error for negated comparison, got %v", err)
+		}
+		if _, err := Load[struct {
+			A string `env:"A?"`
+			B string `env:"B?" when:"@A|shout"`
+		}](context.Background(), env.New()); !errors.As(err, &syntaxErr) || syntaxErr.Offset != 3 {
+			t.Fatalf("expected syntax error at offset 3 for unknown filter, got %v", err)
+		}
+		if _, err := Load[struct {
+			B string `env:"B?" when:"@Missing"`
+		}](context.Background(), env.New()); !errors.Is(err, utils.ErrUnboundVariable) {
+			t.Fatalf("expected unbound variable error, got %v", err)
+		}
+	})
+}
+
+func TestLoadComputed(t *testing.T) {
+	t.Setenv("HOST", "db.internal")
+	t.Setenv("PORT", "5432")
+	t.Setenv("REPLICAS", "3")
+	t.Setenv("ENV", "Staging")
+	t.Setenv("TIMEOUT", "1m30s")
+
+	type Config struct {
+		Host     string        `env:"HOST"`
+		Port     int           `env:"PORT"`
+		Replicas int           `env:"REPLICAS"`
+		Env      string        `env:"ENV"`
+		Timeout  time.Duration `env:"TIMEOUT"`
+		Fallback string        `env:"FALLBACK?"`
+
+		Address  string  `cfg:"@Host + ':' + str(@Port)"`
+		Workers  int     `cfg:"@Replicas * 2 + 1"`
+		Ratio    float64 `cfg:"@Replicas / 2.0"`
+		Name     string  `cfg:"coalesce(@Fallback, @Database.Name, 'app')"`
+		EnvName  string  `cfg:"lower(@Env)"`
+		IsProd   bool    `cfg:"lower(@Env) == 'production' || @Replicas > 5"`
+		Deadline string  `cfg:"@Timeout"`
+		Pool     int     `cfg:"if(@Replicas > 2, max(@Replicas, 4), 1)"`
+		Database struct {
+			Name string `env:"DB_NAME?"`
+		}
+	}
+
+	if env, err := Load[Config](context.Background(), env.New()); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	} else {
+		for name, actual := range map[string][2]any{
+			"Address":  {"db.internal:5432", env.Address},
+			"Workers":  {7, env.Workers},
+			"Ratio":    {1.5, env.Ratio},
+			"Name":     {"app", env.Name},
+			"EnvName":  {"staging", env.EnvName},
+			"IsProd":   {false, env.IsProd},
+			"Deadline": {"1m30s", env.Deadline},
+			"Pool":     {4, env.Pool},
+		} {
+			if actual[0] != actual[1] {
+				t.Errorf("expected %s=%v, got %v", name, actual[0], actual[1])
+			}
+		}
+	}
+
+	t.Run("Detects circular references", func(t *testing.T) {
+		if _, err := Load[struct {
+			A int `cfg:"@B + 1"`
+			B int `cfg:"@A + 1"`
+		}](context.Background(), env.New()); !errors.Is(err, utils.ErrCircularDependency) {
+			t.Fatalf("expected circular dependency error, got %v", err)
+		}
+	})
+
+	t.Run("Reports syntax errors with their position", func(t *testing.T) {
+		var syntaxErr *SyntaxError
+		if _, err := Load[struct {
+			A int `cfg:"1 + * 2"`
+		}](context.Background(), env.New()); !errors.As(err, &syntaxErr) || syntaxErr.Offset != 4 {
+			t.Fatalf("expected syntax error at offset 4, got %v", err)
+		}
+		if _, err := Load[struct {
+			A int `cfg:"exec('rm')"`
+		}](context.Background(), env.New()); !errors.As(err, &syntaxErr) || syntaxErr.Offset != 0 {
+			t.Fatalf("expected syntax error for unknown function, got %v", err)
+		}
+	})
+
+	t.Run("Reports type errors", func(t *testing.T) {
+		if _, err := Load[struct {
+			Port int    `env:"PORT"`
+			A    string `cfg:"'port ' + @Port"`
+		}](context.Background(), env.New()); err == nil || !strings.Contains(err.Error(), "string and int") {
+			t.Fatalf("expected type error, got %v", err)
+		}
+		if _, err := Load[struct {
+			A int `cfg:"1 / 0"`
+		}](context.Background(), env.New()); err == nil || !strings.Contains(err.Error(), "division by zero") {
+			t.Fatalf("expected division by zero error, got %v", err)
+		}
+	})
+
+	t.Run("Can't be combined with loader tags", func(t *testing.T) {
+		if _, err := Load[struct {
+			A int `env:"A" cfg:"1"`
+		}](context.Background(), env.New()); err == nil {
+			t.Fatal("expected error for computed field with a loader tag")
+		}
+	})
+}
+
+func TestLoadDeclaredDependencies(t *testing.T) {
+	t.Setenv("SETTINGS", `{"region": "eu-west-1", "limits": {"rps": 100}}`)
+
+	t.Run("Loads declared dependencies first", func(t *testing.T) {
+		result, err := Load[struct {
+			Region   string `pick:"Settings:region"`
+			RPS      int    `pick:"Settings:limits.rps"`
+			Settings string `env:"SETTINGS"`
+		}](context.Background(), env.New(), pickLoader{})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if result.Region != "eu-west-1" || result.RPS != 100 {
+			t.Fatalf("expected eu-west-1 and 100, got %q and %d", result.Region, result.RPS)
+		}
+	})
+
+	t.Run("Passes tags as written", func(t *testing.T) {
+		// @ has no meaning in the loader's grammar
+		t.Setenv("SETTINGS", `{"@user": "admin"}`)
+
+		result, err := Load[struct {
+			User     string `pick:"Settings:@user"`
+			Settings string `env:"SETTINGS"`
+		}](context.Background(), env.New(), pickLoader{})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if result.User != "admin" {
+			t.Fatalf("expected admin, got %q", result.User)
+		}
+	})
+
+	t.Run("Resolves references unless tags are raw", func(t *testing.T) {
+		t.Setenv("SETTINGS", `{"user": "admin"}`)
+		t.Setenv("KEY", "user")
+
+		result, err := Load[struct {
+			User     string `pick:"Settings:@Key"`
+			Settings string `env:"SETTINGS"`
+			Key      string `env:"KEY"`
+		}](context.Background(), env.New(), resolvingPickLoader{})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if result.User != "admin" {
+			t.Fatalf("expected admin, got %q", result.User)
+		}
+	})
+
+	t.Run("Detects circular dependencies", func(t *testing.T) {
+		_, err := Load[struct {
+			A string `pick:"B:key"`
+			B string `env:"SETTINGS=@A"`
+		}](context.Background(), env.New(), pickLoader{})
+		if !errors.Is(err, utils.ErrCircularDependency) {
+			t.Fatalf("expected ErrCircularDependency, got %v", err)
+		}
+	})
+
+	t.Run("Detects unbound dependencies", func(t *testing.T) {
+		_, err := Load[struct {
+			A string `pick:"Missing:key"`
+		}](context.Background(), pickLoader{})
+		if !errors.Is(err, utils.ErrUnboundVariable) {
+			t.Fatalf("expected ErrUnboundVariable, got %v", err)
+		}
+	})
+}
+
+func TestLoadBatch(t *testing.T) {
+	t.Setenv("FALLBACK", "from-env")
+
+	loader := &mapBatchLoader{values: map[string]string{"a": "1", "b": "2", "1-c": "3", "e": "5"}}
+
+	result, err := Load[struct {
+		A int    `batch:"a"`
+		B int    `batch:"b"`
+		C int    `batch:"@A||-c"`
+		D string `batch:"d" env:"FALLBACK"`
+		E int    `env:"MISSING?" batch:"e"`
+	}](context.Background(), loader, env.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.A != 1 || result.B != 2 || result.C != 3 || result.D != "from-env" || result.E != 5 {
+		t.Fatalf("unexpected result %+v", result)
+	}
+
+	// A, B, D and E are ready at once, then C once A is loaded
+	if !reflect.DeepEqual(loader.batches, [][]string{{"a", "b", "d", "e"}, {"1-c"}}) {
+		t.Fatalf("unexpected batches %v", loader.batches)
+	}
+}
+
+func TestLoadWithProvenance(t *testing.T) {
+	t.Setenv("PROVENANCE_HOST", "db.internal")
+
+	_, provenance, err := LoadWithProvenance[struct {
+		Host     string `env:"PROVENANCE_HOST"`
+		Port     int    `env:"PROVENANCE_PORT?" default:"5432"`
+		Addr     string `cfg:"@Host + ':' + str(@Port)"`
+		Optional string `env:"PROVENANCE_OPTIONAL?"`
+	}](context.Background(), env.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := Provenance{
+		"Host": {Tag: "env"},
+		"Port": {Tag: "default"},
+		"Addr": {Tag: "cfg"},
+	}
+	if !reflect.DeepEqual(provenance, expected) {
+		t.Fatalf("expected %v, got %v", expected, provenance)
+	}
+}
+
+// mapBatchLoader loads fields from a map in batches, recording the tags of
+// each batch
+type mapBatchLoader struct {
+	values  map[string]string
+	batches [][]string
+}
+
+func (*mapBatchLoader) GocfgLoaderName() string { return "batch" }
+
+func (l *mapBatchLoader) Load(ctx context.Context, field reflect.StructField, value reflect.Value, tag string) error {
+	return l.LoadBatch(ctx, []FieldRequest{{Field: field, Value: value, Tag: tag}})[0]
+}
+
+func (l *mapBatchLoader) LoadBatch(_ context.Context, requests []FieldRequest) []error {
+	tags := make([]string, len(requests))
+	errs := make([]error, len(requests))
+	for i, request := range requests {
+		tags[i] = request.Tag
+		if v, ok := l.values[request.Tag]; ok {
+			errs[i] = utils.SetFieldValue(request.Value, v)
+		} else {
+			errs[i] = utils.NotFoundError(false, "%s not found", request.Tag)
+		}
+	}
+	l.batches = append(l.batches, tags)
+	return errs
+}
+
+// pickLoader reads a key from a JSON document in another field, with tags
+// like pick:"Settings:limits.rps", declaring the field it reads
+type pickLoader struct{}
+
+func (pickLoader) GocfgLoaderName() string { return "pick" }
+func (pickLoader) GocfgRawTags() bool      { return true }
+
+func (pickLoader) GocfgDependencies(_ reflect.StructField, tag string) []string {
+	path, _, _ := strings.Cut(tag, ":")
+	return []string{path}
+}
+
+func (pickLoader) Load(ctx context.Context, _ reflect.StructField, value reflect.Value, tag string) error {
+	path, keys, _ := strings.Cut(tag, ":")
+	document, ok := LookupField(ctx, path)
+	if !ok {
+		return fmt.Errorf("%s not loaded", path)
+	}
+
+	var v any
+	if err := json.Unmarshal([]byte(document), &v); err != nil {
+		return err
+	}
+	for _, key := range strings.Split(keys, ".") {
+		object, _ := v.(map[string]any)
+		if v, ok = object[key]; !ok {
+			return utils.NotFoundError(false, "key %s not found in %s", keys, path)
+		}
+	}
+
+	return utils.SetFieldValue(value, fmt.Sprint(v))
+}
+
+// resolvingPickLoader is a pickLoader whose tags have their references
+// resolved, as in pick:"Settings:@Key"
+type resolvingPickLoader struct{ pickLoader }
+
+func (resolvingPickLoader) GocfgRawTags() bool { return false }
+
+// staticTagLoader loads every untagged field with the same tag from the env
+// loader, for testing many tags against one config type
+type staticTagLoader string
+
+func (staticTagLoader) GocfgLoaderName() string { return "statictag" }
+func (staticTagLoader) GocfgEscapedTags() bool  { return true }
+
+func (s staticTagLoader) GocfgUntaggedTag(reflect.StructField) (string, bool) {
+	return string(s), true
+}
+
+func (staticTagLoader) Load(ctx context.Context, field reflect.StructField, value reflect.Value, tag string) error {
+	return env.New().Load(ctx, field, value, tag)
+}
+
+// pointerLoader points int pointer fields at the number in their tag, or
+// leaves them nil for "nil"
+type pointerLoader struct{}
+
+func (pointerLoader) GocfgLoaderName() string { return "pointer" }
+
+func (pointerLoader) Load(_ context.Context, _ reflect.StructField, value reflect.Value, tag string) error {
+	if tag == "nil" {
+		return nil
+	}
+
+	n, err := strconv.Atoi(tag)
+	if err != nil {
+		return err
+	}
+	value.Set(reflect.ValueOf(&n))
+	return nil
+}
+
+func TestLoadNested(t *testing.T) {
+	type DBConfig struct {
+		Host string `env:"DB_HOST"`
+		Port int    `env:"DB_PORT=5432"`
+	}
+
+	t.Run("Loads fields of untagged nested structs", func(t *testing.T) {
+		t.Setenv("DB_HOST", "localhost")
+		if env, err := Load[struct {
+			Database DBConfig
+		}](context.Background(), env.New()); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		} else if env.Database.Host != "localhost" {
+			t.Fatalf("expected Database.Host=localhost, got %s", env.Database.Host)
+		} else if env.Database.Port != 5432 {
+			t.Fatalf("expected Database.Port=5432, got %d", env.Database.Port)
+		}
+	})
+
+	t.Run("Loads promoted fields of embedded structs", func(t *testing.T) {
+		t.Setenv("DB_HOST", "localhost")
+		if env, err := Load[struct {
+			DBConfig
+		}](context.Background(), env.New()); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		} else if env.Host != "localhost" {
+			t.Fatalf("expected Host=localhost, got %s", env.Host)
+		}
+	})
+
+	t.Run("Handles references to nested fields", func(t *testing.T) {
+		t.Setenv("DB_HOST", "DB_URL")
+		t.Setenv("DB_URL", "postgres://localhost")
+		if env, err := Load[struct {
+			URL      string `env:"@Database.Host"`
+			Database DBConfig
+		}](context.Background(), env.New()); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		} else if env.URL != "postgres://localhost" {
+			t.Fatalf("expected URL=postgres://localhost, got %s", env.URL)
+		}
+	})
+
+	t.Run("Reports errors with the field path", func(t *testing.T) {
+		if _, err := Load[struct {
+			Database DBConfig
+		}](context.Background(), env.New()); err == nil {
+			t.Fatal("expected error for missing DB_HOST")
+		} else if !strings.Contains(err.Error(), "Database.Host") {
+			t.Fatalf("expected error to mention Database.Host, got %s", err)
+		}
+	})
+
+	t.Run("Leaves structs that decode themselves whole", func(t *testing.T) {
+		if _, err := Load[struct {
+			Started time.Time
+		}](context.Background(), env.New()); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	})
+}
+
+func TestLoadDefaults(t *testing.T) {
+	t.Run("Uses the default when no loader has a value", func(t *testing.T) {
+		if env, err := Load[struct {
+			Port    int    `env:"PORT" default:"8080"`
+			Host    string `env:"HOST?" default:"localhost"`
+			Timeout int    `default:"30"`
+		}](context.Background(), env.New()); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		} else if env.Port != 8080 || env.Host != "localhost" || env.Timeout != 30 {
+			t.Fatalf("expected defaults, got %+v", env)
+		}
+	})
+
+	t.Run("Prefers loaded values", func(t *testing.T) {
+		t.Setenv("PORT", "9090")
+		if env, err := Load[struct {
+			Port int `env:"PORT" default:"8080"`
+		}](context.Background(), env.New()); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		} else if env.Port != 9090 {
+			t.Fatalf("expected Port=9090, got %d", env.Port)
+		}
+	})
+
+	t.Run("Resolves references in defaults", func(t *testing.T) {
+		t.Setenv("REGION", "us-west-2")
+		if env, err := Load[struct {
+			Bucket string `env:"BUCKET" default:"@Region||-fallback"`
+			Region string `env:"REGION"`
+		}](context.Background(), env.New()); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		} else if env.Bucket != "us-west-2-fallback" {
+			t.Fatalf("expected Bucket=us-west-2-fallback, got %s", env.Bucket)
+		}
+	})
+
+	t.Run("Detects circular references through defaults", func(t *testing.T) {
+		if _, err := Load[struct {
+			A string `default:"@B"`
+			B string `default:"@A"`
+		}](context.Background(), env.New()); !errors.Is(err, utils.ErrCircularDependency) {
+			t.Fatalf("expected circular dependency error, got %v", err)
+		}
+	})
+
+	t.Run("Does not hide other errors", func(t *testing.T) {
+		t.Setenv("PORT", "not-a-number")
+		if _, err := Load[struct {
+			Port int `env:"PORT" default:"8080"`
+		}](context.Background(), env.New()); err == nil {
+			t.Fatal("expected error for invalid PORT")
+		}
+	})
+}
+
+func TestLoadRequired(t *testing.T) {
+	t.Run("Accepts supplied empty values", func(t *testing.T) {
+		t.Setenv("NAME", "")
+		if env, err := Load[struct {
+			Name  string `env:"NAME?" required:"true"`
+			Count int    `env:"COUNT=" required:"true"`
+		}](context.Background(), env.New()); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		} else if env.Name != "" || env.Count != 0 {
+			t.Fatalf("expected zero values, got %+v", env)
+		}
+	})
+
+	t.Run("Rejects values not supplied", func(t *testing.T) {
+		if _, err := Load[struct {
+			Database struct {
+				Port int `env:"DB_PORT?" required:"true"`
+			}
+		}](context.Background(), env.New()); !errors.Is(err, utils.ErrMissingRequired) {
+			t.Fatalf("expected missing required error, got %v", err)
+		} else if !strings.Contains(err.Error(), "Database.Port") {
+			t.Fatalf("expected error to mention Database.Port, got %s", err)
+		}
+	})
+
+	t.Run("Rejects zero values when nonzero", func(t *testing.T) {
+		t.Run("empty", func(t *testing.T) {
+			t.Setenv("NAME", "")
+			if _, err := Load[struct {
+				Name string `env:"NAME" required:"nonzero"`
+			}](context.Background(), env.New()); !errors.Is(err, utils.ErrMissingRequired) {
+				t.Fatalf("expected missing required error, got %v", err)
+			} else if !strings.Contains(err.Error(), "Name") {
+				t.Fatalf("expected error to mention Name, got %s", err)
+			}
+		})
+
+		t.Run("zero", func(t *testing.T) {
+			t.Setenv("TIMEOUT", "0")
+			if _, err := Load[struct {
+				Timeout time.Duration `env:"TIMEOUT" required:"nonzero"`
+			}](context.Background(), env.New()); !errors.Is(err, utils.ErrMissingRequired) {
+				t.Fatalf("expected missing required error, got %v", err)
+			} else if !strings.Contains(err.Error(), "Timeout") {
+				t.Fatalf("expected error to mention Timeout, got %s", err)
+			}
+		})
+	})
+
+	t.Run("Accepts defaults", func(t *testing.T) {
+		if env, err := Load[struct {
+			Retries int `env:"RETRIES?" default:"3" required:"nonzero"`
+		}](context.Background(), env.New()); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		} else if env.Retries != 3 {
+			t.Fatalf("expected Retries=3, got %d", env.Retries)
+		}
+	})
+
+	t.Run("Rejects invalid tags", func(t *testing.T) {
+		if _, err := Load[struct {
+			Name string `env:"NAME?" required:"yes"`
+		}](context.Background(), env.New()); err == nil {
+			t.Fatal("expected error for invalid required tag")
+		}
+	})
+}
+
+func TestExpand(t *testing.T) {
+	vars := map[string]string{
+		"DB_USER": "app",
+		"DB_PASS": "${DB_USER}-secret",
+		"DB_HOST": "",
+		"LOOP_A":  "${LOOP_B}",
+		"LOOP_B":  "x${LOOP_A}",
+	}
+	lookup := func(name string) (string, bool) {
+		value, exists := vars[name]
+		return value, exists
+	}
+
+	for _, tc := range []struct{ in, expected string }{
+		{"postgres://${DB_USER}:${DB_PASS}@db/app", "postgres://app:app-secret@db/app"},
+		{"${DB_HOST:-localhost}", "localhost"},
+		{"${DB_HOST-localhost}", ""},
+		{"${DB_PORT-5432}", "5432"},
+		{"${DB_PORT:-${DB_USER}}", "app"},
+		{"${MISSING}", ""},
+		{"$$HOME $HOME", "$HOME $HOME"},
+	} {
+		if actual, err := Expand(tc.in, lookup); err != nil {
+			t.Errorf("Expand(%q): unexpected error: %s", tc.in, err)
+		} else if actual != tc.expected {
+			t.Errorf("Expand(%q): expected %q, got %q", tc.in, tc.expected, actual)
+		}
+	}
+
+	t.Run("Detects cycles between variables", func(t *testing.T) {
+		if _, err := Expand("${LOOP_A}", lookup); !errors.Is(err, utils.ErrCircularDependency) {
+			t.Fatalf("expected circular dependency error, got %v", err)
+		}
+	})
+
+	t.Run("Rejects malformed expansions", func(t *testing.T) {
+		for _, in := range []string{"${DB_USER", "${}", "${DB_USER:+x}"} {
+			if _, err := Expand(in, lookup); err == nil {
+				t.Errorf("Expand(%q): expected error", in)
+			}
+		}
+	})
+}
+
 type jsonValue map[string]any
 
 var _ json.Unmarshaler = (*jsonValue)(nil)
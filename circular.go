@@ -2,19 +2,32 @@ package gocfg
 
 import (
 	"fmt"
+	"reflect"
 
 	"github.com/Gardego5/gocfg/utils"
 )
 
 type node struct {
-	fieldName    string
-	fieldIndex   int
-	tag          string
-	loader       Loader
+	fieldName    string                // Dotted path from the config struct
+	fieldIndex   []int                 // Index sequence for FieldByIndex
+	fieldPath    []reflect.StructField // Struct fields leading to the field
+	sources      []source
+	defaultTag   string // Tag of the default value, if hasDefault
+	hasDefault   bool
+	required     requirement
+	conditions   []condition // When tags, all of which must hold
+	expr         expr        // Expression computing the field, if any
 	dependencies []string
 	resolved     bool
 }
 
+// source is one of the loaders a field can be loaded from, with its tag
+type source struct {
+	tag    string
+	loader Loader
+	raw    bool // Pass the tag as written, see RawTagLoader
+}
+
 // detectCircularDependencies checks for circular dependencies in the graph
 func detectCircularDependencies(nodes map[string]*node) error {
 	visited := make(map[string]bool)
@@ -0,0 +1,103 @@
+package gocfg
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/Gardego5/gocfg/utils"
+)
+
+// whenTagName is the tag making a field conditional on other fields, as in
+// when:"@CacheBackend==redis". Fields whose condition is false are not loaded
+// and not required. On a nested struct it applies to all of its fields.
+const whenTagName = "when"
+
+// condition is a parsed when tag:
+//   - @Field - Field is not the zero value
+//   - !@Field - Field is the zero value
+//   - @Field==value - Field formats as value
+//   - @Field!=value - Field doesn't format as value
+//
+// The field may use a format verb and filters, as in @Env|lower==prod, and the
+// value may use the full tag syntax, including references.
+type condition struct {
+	field  token  // Reference to the field tested
+	negate bool   // !@Field
+	op     string // "", "==" or "!="
+	value  string // Tag of the value compared with
+
+	references []string // Fields the condition depends on
+}
+
+// parseCondition parses a when tag
+func parseCondition(tag string) (cond condition, err error) {
+	trimmed := strings.TrimSpace(tag)
+	offset := len(tag) - len(strings.TrimLeft(tag, " \t"))
+	syntaxError := func(at int, msg string) error {
+		return &SyntaxError{Tag: tag, Offset: offset + at, Msg: msg}
+	}
+
+	if strings.HasPrefix(trimmed, "!") {
+		cond.negate = true
+		trimmed, offset = trimmed[1:], offset+1
+	}
+
+	// Split the field from the comparison
+	left, opOffset := trimmed, -1
+	for _, op := range []string{"==", "!="} {
+		if i := utils.IndexTag(trimmed, op); i >= 0 && (opOffset < 0 || i < opOffset) {
+			opOffset, cond.op = i, op
+		}
+	}
+	if opOffset >= 0 {
+		if cond.negate {
+			return cond, syntaxError(-1, "! can't be combined with a comparison")
+		}
+		left = trimmed[:opOffset]
+		cond.value = strings.TrimSpace(trimmed[opOffset+2:])
+	}
+
+	tokens, err := tokenize(strings.TrimRight(left, " \t"))
+	if err != nil {
+		if syntaxErr, ok := err.(*SyntaxError); ok {
+			return cond, syntaxError(syntaxErr.Offset, syntaxErr.Msg)
+		}
+		return cond, err
+	}
+	if len(tokens) != 1 || tokens[0].kind != referenceToken {
+		return cond, syntaxError(0, "expected a condition like @Field, !@Field or @Field==value")
+	}
+	cond.field = tokens[0]
+	cond.references = append(cond.references, cond.field.text)
+
+	if cond.op != "" {
+		valueReferences, err := parseTag(cond.value)
+		if err != nil {
+			return cond, fmt.Errorf("in value of condition %q: %w", tag, err)
+		}
+		cond.references = append(cond.references, valueReferences...)
+	}
+
+	return cond, nil
+}
+
+// holds evaluates a condition against the config struct
+func (c condition) holds(configValue reflect.Value) (bool, error) {
+	field := lookupField(configValue, c.field.text)
+	if !field.IsValid() {
+		return false, fmt.Errorf("%w: %s", utils.ErrUnboundVariable, c.field.text)
+	}
+
+	if c.op == "" {
+		return field.IsZero() == c.negate, nil
+	}
+
+	value, err := resolveTag(c.value, configValue)
+	if err != nil {
+		return false, err
+	}
+
+	equal := formatReference(c.field, field) == utils.UnescapeTag(value)
+	return equal == (c.op == "=="), nil
+}
@@ -0,0 +1,216 @@
+package gocfg
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Expand replaces ${VAR} and ${VAR:-default} in s with values from lookup.
+// Names may be dotted field paths, as in ${Database.Host}.
+// Values are expanded in turn, so variables may refer to other variables,
+// and a cycle between them is reported as utils.ErrCircularDependency.
+//
+// Supported forms:
+//   - ${VAR} - The value of VAR, or "" when unset
+//   - ${VAR:-default} - The default when VAR is unset or empty
+//   - ${VAR-default} - The default when VAR is unset
+//   - $$ - A literal $
+//
+// Defaults may contain expansions themselves.
+func Expand(s string, lookup func(name string) (string, bool)) (string, error) {
+	e := &expander{
+		lookup:   lookup,
+		nodes:    make(map[string]*node),
+		values:   make(map[string]string),
+		exists:   make(map[string]bool),
+		expanded: make(map[string]string),
+	}
+
+	// Discover every variable reachable from s, then check them for cycles
+	if err := e.discover(s); err != nil {
+		return "", err
+	}
+	if err := detectCircularDependencies(e.nodes); err != nil {
+		return "", err
+	}
+
+	return e.expand(s)
+}
+
+type expander struct {
+	lookup   func(name string) (string, bool)
+	nodes    map[string]*node  // Dependency graph of variables
+	values   map[string]string // Raw values of variables
+	exists   map[string]bool   // Whether variables are set
+	expanded map[string]string // Expanded values of variables
+}
+
+// expansion is a single ${...} or $$ in a string
+type expansion struct {
+	start, end   int // Position of the expansion in the string
+	name         string
+	defaultValue string
+	hasDefault   bool
+	orEmpty      bool // Use the default for empty values too (:-)
+	literal      bool // $$
+}
+
+// parseExpansions finds the expansions in s
+func parseExpansions(s string) ([]expansion, error) {
+	var expansions []expansion
+
+	for i := 0; i < len(s); i++ {
+		if s[i] != '$' || i+1 >= len(s) {
+			continue
+		}
+
+		switch s[i+1] {
+		case '$':
+			expansions = append(expansions, expansion{start: i, end: i + 2, literal: true})
+			i++
+
+		case '{':
+			// Find the matching brace, allowing nested expansions in defaults
+			depth, end := 0, -1
+			for j := i + 1; j < len(s) && end < 0; j++ {
+				switch s[j] {
+				case '{':
+					depth++
+				case '}':
+					if depth--; depth == 0 {
+						end = j
+					}
+				}
+			}
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated expansion at position %d in %q", i, s)
+			}
+
+			x := expansion{start: i, end: end + 1}
+			body := s[i+2 : end]
+
+			nameEnd := 0
+			for nameEnd < len(body) && isPathChar(body, nameEnd) {
+				nameEnd++
+			}
+			x.name = body[:nameEnd]
+			if x.name == "" {
+				return nil, fmt.Errorf("missing variable name at position %d in %q", i, s)
+			}
+
+			switch rest := body[nameEnd:]; {
+			case rest == "":
+			case strings.HasPrefix(rest, ":-"):
+				x.defaultValue, x.hasDefault, x.orEmpty = rest[2:], true, true
+			case strings.HasPrefix(rest, "-"):
+				x.defaultValue, x.hasDefault = rest[1:], true
+			default:
+				return nil, fmt.Errorf("invalid expansion %q at position %d", s[i:end+1], i)
+			}
+
+			expansions = append(expansions, x)
+			i = end
+		}
+	}
+
+	return expansions, nil
+}
+
+// discover adds a node for every variable referenced by s, directly or
+// through the values and defaults of other variables
+func (e *expander) discover(s string) error {
+	expansions, err := parseExpansions(s)
+	if err != nil {
+		return err
+	}
+
+	for _, x := range expansions {
+		if x.literal {
+			continue
+		}
+
+		if x.hasDefault {
+			if err := e.discover(x.defaultValue); err != nil {
+				return err
+			}
+		}
+
+		if _, seen := e.nodes[x.name]; seen {
+			continue
+		}
+
+		value, exists := e.lookup(x.name)
+		e.values[x.name], e.exists[x.name] = value, exists
+
+		n := &node{fieldName: x.name}
+		e.nodes[x.name] = n
+
+		valueExpansions, err := parseExpansions(value)
+		if err != nil {
+			return fmt.Errorf("in variable %s: %w", x.name, err)
+		}
+		for _, dep := range valueExpansions {
+			if !dep.literal {
+				n.dependencies = append(n.dependencies, dep.name)
+			}
+		}
+
+		if err := e.discover(value); err != nil {
+			return fmt.Errorf("in variable %s: %w", x.name, err)
+		}
+	}
+
+	return nil
+}
+
+// expand replaces the expansions in s. Every variable must already have been
+// discovered.
+func (e *expander) expand(s string) (string, error) {
+	expansions, err := parseExpansions(s)
+	if err != nil {
+		return "", err
+	}
+
+	var result strings.Builder
+	last := 0
+	for _, x := range expansions {
+		result.WriteString(s[last:x.start])
+		last = x.end
+
+		if x.literal {
+			result.WriteByte('$')
+			continue
+		}
+
+		value, err := e.variable(x.name)
+		if err != nil {
+			return "", err
+		}
+
+		if x.hasDefault && (!e.exists[x.name] || (x.orEmpty && value == "")) {
+			if value, err = e.expand(x.defaultValue); err != nil {
+				return "", err
+			}
+		}
+
+		result.WriteString(value)
+	}
+	result.WriteString(s[last:])
+
+	return result.String(), nil
+}
+
+// variable returns the expanded value of a variable
+func (e *expander) variable(name string) (string, error) {
+	if value, done := e.expanded[name]; done {
+		return value, nil
+	}
+
+	value, err := e.expand(e.values[name])
+	if err != nil {
+		return "", fmt.Errorf("in variable %s: %w", name, err)
+	}
+
+	e.expanded[name] = value
+	return value, nil
+}
@@ -0,0 +1,752 @@
+package gocfg
+
+import (
+	"encoding"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/Gardego5/gocfg/utils"
+)
+
+// exprTagName is the tag computing a field from other fields with an
+// expression, as in cfg:"@Host + ':' + str(@Port)".
+//
+// Expressions have integers, floats, 'strings' and true/false, references to
+// other fields, the operators + - * / % == != < <= > >= && || ! and
+// parentheses, and the functions in exprFunctions. + joins strings as well as
+// adding numbers. They can't do anything besides computing a value.
+const exprTagName = "cfg"
+
+// expr is a parsed expression
+type expr interface {
+	eval(configValue reflect.Value) (any, error)
+}
+
+type (
+	exprLiteral struct{ value any }
+	exprRef     struct {
+		path   string
+		offset int
+	}
+	exprUnary struct {
+		op      string
+		operand expr
+		offset  int
+	}
+	exprBinary struct {
+		op          string
+		left, right expr
+		offset      int
+	}
+	exprCall struct {
+		name   string
+		args   []expr
+		offset int
+	}
+)
+
+// exprError reports an expression that failed to evaluate
+type exprError struct {
+	offset int
+	msg    string
+}
+
+func (e *exprError) Error() string { return fmt.Sprintf("at offset %d: %s", e.offset, e.msg) }
+
+func evalError(offset int, format string, args ...any) error {
+	return &exprError{offset: offset, msg: fmt.Sprintf(format, args...)}
+}
+
+// parseExpr parses an expression, returning it with the fields it references
+func parseExpr(src string) (expr, []string, error) {
+	p := &exprParser{src: src}
+	p.next()
+
+	e, err := p.parseOr()
+	if err != nil {
+		return nil, nil, err
+	}
+	if p.tok.kind != exprEOFToken || p.err != nil {
+		return nil, nil, p.syntaxError("unexpected %s", p.tok)
+	}
+
+	return e, p.references, nil
+}
+
+// evalExpr evaluates an expression, setting the field with its result
+func evalExpr(e expr, configValue, fieldValue reflect.Value) error {
+	value, err := e.eval(configValue)
+	if err != nil {
+		return err
+	}
+	return utils.SetFieldValue(fieldValue, formatExprValue(value))
+}
+
+type exprTokenKind int
+
+const (
+	exprEOFToken exprTokenKind = iota
+	exprNumberToken
+	exprStringToken
+	exprIdentToken
+	exprReferenceToken
+	exprOperatorToken
+)
+
+type exprToken struct {
+	kind   exprTokenKind
+	text   string // Unquoted for strings
+	offset int
+}
+
+func (t exprToken) String() string {
+	switch t.kind {
+	case exprEOFToken:
+		return "end of expression"
+	case exprStringToken:
+		return strconv.Quote(t.text)
+	case exprReferenceToken:
+		return "@" + t.text
+	default:
+		return "'" + t.text + "'"
+	}
+}
+
+type exprParser struct {
+	src        string
+	pos        int
+	tok        exprToken
+	err        error // Error reading tok
+	references []string
+}
+
+func (p *exprParser) syntaxError(format string, args ...any) error {
+	if p.err != nil {
+		return p.err
+	}
+	return &SyntaxError{Tag: p.src, Offset: p.tok.offset, Msg: fmt.Sprintf(format, args...)}
+}
+
+// exprOperators are the operators, longest first
+var exprOperators = []string{"==", "!=", "<=", ">=", "&&", "||", "+", "-", "*", "/", "%", "<", ">", "!", "(", ")", ","}
+
+// next reads the next token into p.tok
+func (p *exprParser) next() {
+	for p.pos < len(p.src) && strings.IndexByte(" \t\n", p.src[p.pos]) >= 0 {
+		p.pos++
+	}
+
+	start := p.pos
+	if p.pos >= len(p.src) {
+		p.tok = exprToken{kind: exprEOFToken, offset: start}
+		return
+	}
+
+	switch c := p.src[p.pos]; {
+	case c >= '0' && c <= '9':
+		for p.pos < len(p.src) && (isDigitChar(p.src[p.pos]) || p.src[p.pos] == '.') {
+			p.pos++
+		}
+		p.tok = exprToken{kind: exprNumberToken, text: p.src[start:p.pos], offset: start}
+
+	case c == '\'':
+		var text strings.Builder
+		for p.pos++; p.pos < len(p.src) && p.src[p.pos] != '\''; p.pos++ {
+			if p.src[p.pos] == '\\' && p.pos+1 < len(p.src) {
+				p.pos++
+			}
+			text.WriteByte(p.src[p.pos])
+		}
+		if p.pos >= len(p.src) {
+			p.err = &SyntaxError{Tag: p.src, Offset: start, Msg: "unterminated string"}
+			p.tok = exprToken{kind: exprEOFToken, offset: start}
+			return
+		}
+		p.pos++
+		p.tok = exprToken{kind: exprStringToken, text: text.String(), offset: start}
+
+	case c == '@':
+		p.pos++
+		for p.pos < len(p.src) && isPathChar(p.src, p.pos) {
+			p.pos++
+		}
+		if p.pos == start+1 {
+			p.err = &SyntaxError{Tag: p.src, Offset: start, Msg: "expected a field name after @"}
+		}
+		p.tok = exprToken{kind: exprReferenceToken, text: p.src[start+1 : p.pos], offset: start}
+
+	case isIdentChar(c):
+		for p.pos < len(p.src) && isIdentChar(p.src[p.pos]) {
+			p.pos++
+		}
+		p.tok = exprToken{kind: exprIdentToken, text: p.src[start:p.pos], offset: start}
+
+	default:
+		for _, op := range exprOperators {
+			if strings.HasPrefix(p.src[p.pos:], op) {
+				p.pos += len(op)
+				p.tok = exprToken{kind: exprOperatorToken, text: op, offset: start}
+				return
+			}
+		}
+		p.err = &SyntaxError{Tag: p.src, Offset: start, Msg: fmt.Sprintf("unexpected character %q", c)}
+		p.tok = exprToken{kind: exprEOFToken, offset: start}
+	}
+}
+
+func isDigitChar(c byte) bool { return c >= '0' && c <= '9' }
+
+// isOperator reports whether the current token is one of ops
+func (p *exprParser) isOperator(ops ...string) bool {
+	if p.tok.kind != exprOperatorToken {
+		return false
+	}
+	for _, op := range ops {
+		if p.tok.text == op {
+			return true
+		}
+	}
+	return false
+}
+
+// parseBinary parses a left-associative chain of ops between operands
+func (p *exprParser) parseBinary(operand func() (expr, error), ops ...string) (expr, error) {
+	left, err := operand()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.isOperator(ops...) {
+		op := p.tok
+		p.next()
+
+		right, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		left = &exprBinary{op: op.text, left: left, right: right, offset: op.offset}
+	}
+
+	return left, nil
+}
+
+func (p *exprParser) parseOr() (expr, error) { return p.parseBinary(p.parseAnd, "||") }
+
+func (p *exprParser) parseAnd() (expr, error) { return p.parseBinary(p.parseComparison, "&&") }
+
+func (p *exprParser) parseComparison() (expr, error) {
+	left, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.isOperator("==", "!=", "<", "<=", ">", ">=") {
+		op := p.tok
+		p.next()
+
+		right, err := p.parseAdditive()
+		if err != nil {
+			return nil, err
+		}
+		if p.isOperator("==", "!=", "<", "<=", ">", ">=") {
+			return nil, p.syntaxError("comparisons can't be chained")
+		}
+		return &exprBinary{op: op.text, left: left, right: right, offset: op.offset}, nil
+	}
+
+	return left, nil
+}
+
+func (p *exprParser) parseAdditive() (expr, error) {
+	return p.parseBinary(p.parseMultiplicative, "+", "-")
+}
+
+func (p *exprParser) parseMultiplicative() (expr, error) {
+	return p.parseBinary(p.parseUnary, "*", "/", "%")
+}
+
+func (p *exprParser) parseUnary() (expr, error) {
+	if p.isOperator("-", "!") {
+		op := p.tok
+		p.next()
+
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &exprUnary{op: op.text, operand: operand, offset: op.offset}, nil
+	}
+
+	return p.parsePrimary()
+}
+
+func (p *exprParser) parsePrimary() (expr, error) {
+	tok := p.tok
+	if p.err != nil {
+		return nil, p.err
+	}
+
+	switch tok.kind {
+	case exprNumberToken:
+		p.next()
+		if i, err := strconv.ParseInt(tok.text, 10, 64); err == nil {
+			return &exprLiteral{value: i}, nil
+		}
+		if f, err := strconv.ParseFloat(tok.text, 64); err == nil {
+			return &exprLiteral{value: f}, nil
+		}
+		return nil, &SyntaxError{Tag: p.src, Offset: tok.offset, Msg: fmt.Sprintf("invalid number %s", tok.text)}
+
+	case exprStringToken:
+		p.next()
+		return &exprLiteral{value: tok.text}, nil
+
+	case exprReferenceToken:
+		p.next()
+		p.references = append(p.references, tok.text)
+		return &exprRef{path: tok.text, offset: tok.offset}, nil
+
+	case exprIdentToken:
+		p.next()
+		switch tok.text {
+		case "true":
+			return &exprLiteral{value: true}, nil
+		case "false":
+			return &exprLiteral{value: false}, nil
+		}
+
+		if _, exists := exprFunctions[tok.text]; !exists && tok.text != "if" {
+			return nil, &SyntaxError{Tag: p.src, Offset: tok.offset, Msg: fmt.Sprintf("unknown function %s", tok.text)}
+		}
+		if !p.isOperator("(") {
+			return nil, p.syntaxError("expected ( after %s", tok.text)
+		}
+		p.next()
+
+		call := &exprCall{name: tok.text, offset: tok.offset}
+		for !p.isOperator(")") {
+			if len(call.args) > 0 {
+				if !p.isOperator(",") {
+					return nil, p.syntaxError("expected , or ) in arguments of %s", tok.text)
+				}
+				p.next()
+			}
+
+			arg, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			call.args = append(call.args, arg)
+		}
+		p.next()
+
+		if call.name == "if" && len(call.args) != 3 {
+			return nil, &SyntaxError{Tag: p.src, Offset: tok.offset, Msg: "if takes a condition and two values"}
+		}
+		return call, nil
+
+	case exprOperatorToken:
+		if tok.text == "(" {
+			p.next()
+			e, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			if !p.isOperator(")") {
+				return nil, p.syntaxError("expected )")
+			}
+			p.next()
+			return e, nil
+		}
+	}
+
+	return nil, p.syntaxError("unexpected %s", tok)
+}
+
+func (e *exprLiteral) eval(reflect.Value) (any, error) { return e.value, nil }
+
+func (e *exprRef) eval(configValue reflect.Value) (any, error) {
+	field := lookupField(configValue, e.path)
+	if !field.IsValid() {
+		return nil, fmt.Errorf("%w: %s", utils.ErrUnboundVariable, e.path)
+	}
+	return exprValue(field), nil
+}
+
+// exprValue converts a field to an expression value. Numbers, strings and
+// bools keep their type; anything else, including types that format
+// themselves like time.Duration, becomes its text.
+func exprValue(field reflect.Value) any {
+	for field.Kind() == reflect.Pointer {
+		if field.IsNil() {
+			return ""
+		}
+		field = field.Elem()
+	}
+
+	if field.Type().Implements(reflect.TypeFor[fmt.Stringer]()) ||
+		field.Type().Implements(reflect.TypeFor[encoding.TextMarshaler]()) {
+		return formatField(field)
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		return field.String()
+	case reflect.Bool:
+		return field.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return field.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(field.Uint())
+	case reflect.Float32, reflect.Float64:
+		return field.Float()
+	default:
+		return formatField(field)
+	}
+}
+
+// formatExprValue formats an expression value as text
+func formatExprValue(value any) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// exprTypeName names the type of an expression value in errors
+func exprTypeName(value any) string {
+	switch value.(type) {
+	case string:
+		return "string"
+	case int64:
+		return "int"
+	case float64:
+		return "float"
+	case bool:
+		return "bool"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
+
+func (e *exprUnary) eval(configValue reflect.Value) (any, error) {
+	operand, err := e.operand.eval(configValue)
+	if err != nil {
+		return nil, err
+	}
+
+	switch v := operand.(type) {
+	case bool:
+		if e.op == "!" {
+			return !v, nil
+		}
+	case int64:
+		if e.op == "-" {
+			return -v, nil
+		}
+	case float64:
+		if e.op == "-" {
+			return -v, nil
+		}
+	}
+
+	return nil, evalError(e.offset, "can't apply %s to %s", e.op, exprTypeName(operand))
+}
+
+func (e *exprBinary) eval(configValue reflect.Value) (any, error) {
+	left, err := e.left.eval(configValue)
+	if err != nil {
+		return nil, err
+	}
+
+	// Short-circuit logical operators
+	if e.op == "&&" || e.op == "||" {
+		l, ok := left.(bool)
+		if !ok {
+			return nil, evalError(e.offset, "can't apply %s to %s", e.op, exprTypeName(left))
+		}
+		if l == (e.op == "||") {
+			return l, nil
+		}
+
+		right, err := e.right.eval(configValue)
+		if err != nil {
+			return nil, err
+		}
+		if r, ok := right.(bool); ok {
+			return r, nil
+		}
+		return nil, evalError(e.offset, "can't apply %s to %s", e.op, exprTypeName(right))
+	}
+
+	right, err := e.right.eval(configValue)
+	if err != nil {
+		return nil, err
+	}
+
+	mismatch := func() error {
+		return evalError(e.offset, "can't apply %s to %s and %s", e.op, exprTypeName(left), exprTypeName(right))
+	}
+
+	switch e.op {
+	case "==":
+		return exprEqual(left, right), nil
+	case "!=":
+		return !exprEqual(left, right), nil
+	}
+
+	// Strings support + and ordering
+	if l, ok := left.(string); ok {
+		r, ok := right.(string)
+		if !ok {
+			return nil, mismatch()
+		}
+		switch e.op {
+		case "+":
+			return l + r, nil
+		case "<":
+			return l < r, nil
+		case "<=":
+			return l <= r, nil
+		case ">":
+			return l > r, nil
+		case ">=":
+			return l >= r, nil
+		}
+		return nil, mismatch()
+	}
+
+	// Integers stay integers, unless mixed with floats
+	if l, ok := left.(int64); ok {
+		if r, ok := right.(int64); ok {
+			switch e.op {
+			case "+":
+				return l + r, nil
+			case "-":
+				return l - r, nil
+			case "*":
+				return l * r, nil
+			case "/", "%":
+				if r == 0 {
+					return nil, evalError(e.offset, "division by zero")
+				}
+				if e.op == "/" {
+					return l / r, nil
+				}
+				return l % r, nil
+			case "<":
+				return l < r, nil
+			case "<=":
+				return l <= r, nil
+			case ">":
+				return l > r, nil
+			case ">=":
+				return l >= r, nil
+			}
+		}
+	}
+
+	l, lok := exprFloat(left)
+	r, rok := exprFloat(right)
+	if !lok || !rok {
+		return nil, mismatch()
+	}
+
+	switch e.op {
+	case "+":
+		return l + r, nil
+	case "-":
+		return l - r, nil
+	case "*":
+		return l * r, nil
+	case "/":
+		if r == 0 {
+			return nil, evalError(e.offset, "division by zero")
+		}
+		return l / r, nil
+	case "%":
+		if r == 0 {
+			return nil, evalError(e.offset, "division by zero")
+		}
+		return math.Mod(l, r), nil
+	case "<":
+		return l < r, nil
+	case "<=":
+		return l <= r, nil
+	case ">":
+		return l > r, nil
+	case ">=":
+		return l >= r, nil
+	}
+
+	return nil, mismatch()
+}
+
+// exprFloat converts a numeric expression value to a float
+func exprFloat(value any) (float64, bool) {
+	switch v := value.(type) {
+	case int64:
+		return float64(v), true
+	case float64:
+		return v, true
+	default:
+		return 0, false
+	}
+}
+
+// exprEqual compares expression values, comparing numbers by value
+func exprEqual(left, right any) bool {
+	if l, ok := exprFloat(left); ok {
+		r, ok := exprFloat(right)
+		return ok && l == r
+	}
+	return left == right
+}
+
+// exprIsZero reports whether an expression value is its type's zero value
+func exprIsZero(value any) bool {
+	switch v := value.(type) {
+	case string:
+		return v == ""
+	case int64:
+		return v == 0
+	case float64:
+		return v == 0
+	case bool:
+		return !v
+	default:
+		return value == nil
+	}
+}
+
+// exprFunctions are the functions expressions can call, besides
+// if(condition, then, else), which only evaluates the value it returns.
+var exprFunctions = map[string]func(args []any) (any, error){
+	"str": unaryFunc(func(v any) (any, error) { return formatExprValue(v), nil }),
+	"int": unaryFunc(func(v any) (any, error) {
+		switch v := v.(type) {
+		case int64:
+			return v, nil
+		case float64:
+			return int64(v), nil
+		case bool:
+			if v {
+				return int64(1), nil
+			}
+			return int64(0), nil
+		default:
+			return strconv.ParseInt(strings.TrimSpace(formatExprValue(v)), 10, 64)
+		}
+	}),
+	"float": unaryFunc(func(v any) (any, error) {
+		if f, ok := exprFloat(v); ok {
+			return f, nil
+		}
+		return strconv.ParseFloat(strings.TrimSpace(formatExprValue(v)), 64)
+	}),
+	"lower": stringFunc(strings.ToLower),
+	"upper": stringFunc(strings.ToUpper),
+	"trim":  stringFunc(strings.TrimSpace),
+	"len": unaryFunc(func(v any) (any, error) {
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("expected a string, got %s", exprTypeName(v))
+		}
+		return int64(len(s)), nil
+	}),
+	"coalesce": func(args []any) (any, error) {
+		for _, arg := range args {
+			if !exprIsZero(arg) {
+				return arg, nil
+			}
+		}
+		if len(args) == 0 {
+			return "", nil
+		}
+		return args[len(args)-1], nil
+	},
+	"min": extremumFunc(func(a, b float64) bool { return a < b }),
+	"max": extremumFunc(func(a, b float64) bool { return a > b }),
+}
+
+func unaryFunc(f func(any) (any, error)) func([]any) (any, error) {
+	return func(args []any) (any, error) {
+		if len(args) != 1 {
+			return nil, fmt.Errorf("expected 1 argument, got %d", len(args))
+		}
+		return f(args[0])
+	}
+}
+
+func stringFunc(f func(string) string) func([]any) (any, error) {
+	return unaryFunc(func(v any) (any, error) {
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("expected a string, got %s", exprTypeName(v))
+		}
+		return f(s), nil
+	})
+}
+
+func extremumFunc(better func(a, b float64) bool) func([]any) (any, error) {
+	return func(args []any) (any, error) {
+		if len(args) == 0 {
+			return nil, fmt.Errorf("expected at least 1 argument")
+		}
+
+		best, bestValue := args[0], 0.0
+		for i, arg := range args {
+			f, ok := exprFloat(arg)
+			if !ok {
+				return nil, fmt.Errorf("expected numbers, got %s", exprTypeName(arg))
+			}
+			if i == 0 || better(f, bestValue) {
+				best, bestValue = arg, f
+			}
+		}
+		return best, nil
+	}
+}
+
+func (e *exprCall) eval(configValue reflect.Value) (any, error) {
+	if e.name == "if" {
+		condition, err := e.args[0].eval(configValue)
+		if err != nil {
+			return nil, err
+		}
+		c, ok := condition.(bool)
+		if !ok {
+			return nil, evalError(e.offset, "if: expected a bool condition, got %s", exprTypeName(condition))
+		}
+		if c {
+			return e.args[1].eval(configValue)
+		}
+		return e.args[2].eval(configValue)
+	}
+
+	args := make([]any, len(e.args))
+	for i, arg := range e.args {
+		value, err := arg.eval(configValue)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = value
+	}
+
+	result, err := exprFunctions[e.name](args)
+	if err != nil {
+		return nil, evalError(e.offset, "%s: %s", e.name, err)
+	}
+	return result, nil
+}
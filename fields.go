@@ -0,0 +1,421 @@
+package gocfg
+
+import (
+	"context"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// UntaggedLoader is implemented by loaders that can also load fields without
+// a tag for any loader, such as the env loader deriving variable names from
+// field paths.
+type UntaggedLoader interface {
+	Loader
+
+	// GocfgUntaggedTag returns the tag to load an untagged field with, or
+	// false to leave the field alone.
+	GocfgUntaggedTag(field reflect.StructField) (tag string, ok bool)
+}
+
+// DefaultLoader is implemented by loaders whose tags can give a default, such
+// as the flag loader's flag:"port=8080". A field without a default tag falls
+// back to the first default its loaders give when none of them has a value.
+type DefaultLoader interface {
+	Loader
+
+	// GocfgDefault returns the default a tag, as written, gives, in the syntax
+	// of the default tag, or false if it gives none.
+	GocfgDefault(field reflect.StructField, tag string) (defaultTag string, ok bool)
+}
+
+// EscapedTagLoader is implemented by loaders with a tag syntax of their own,
+// which split resolved tags with the utils tag helpers, such as utils.CutTag.
+// They receive literal metacharacters escaped, so a referenced value holding
+// a ? or a = is not read as syntax, and unescape each piece with
+// utils.UnescapeTag. Other loaders receive resolved tags unescaped.
+type EscapedTagLoader interface {
+	Loader
+
+	// GocfgEscapedTags reports whether Load is passed escaped tags.
+	GocfgEscapedTags() bool
+}
+
+// DependencyDeclarer is implemented by loaders reading fields their tags
+// don't reference with @, such as templates. The fields a loader declares are
+// loaded before the field, along with the fields its tag references.
+type DependencyDeclarer interface {
+	Loader
+
+	// GocfgDependencies returns the dotted paths of the fields a tag, as
+	// written, reads. A path to a nested struct stands for all of its fields,
+	// and a path to no field fails Load with ErrUnboundVariable.
+	GocfgDependencies(field reflect.StructField, tag string) []string
+}
+
+// RawTagLoader is implemented by loaders with a tag grammar of their own, in
+// which @, || or quotes mean something else, such as templates. Their tags
+// are passed to Load as written, without resolving references, so such
+// loaders usually declare the fields they read with DependencyDeclarer.
+type RawTagLoader interface {
+	Loader
+
+	// GocfgRawTags reports whether Load is passed tags as written.
+	GocfgRawTags() bool
+}
+
+// Finalizer is implemented by loaders that check the configuration as a
+// whole once every field is loaded, such as the env loader's strict mode.
+type Finalizer interface {
+	Loader
+
+	// GocfgFinalize is called at the end of Load, once for each loader.
+	GocfgFinalize(ctx context.Context) error
+}
+
+// defaultTagName is the tag giving a field's value when none of its loaders
+// has one. It may reference other fields like loader tags, as in
+// default:"@Region||-fallback".
+const defaultTagName = "default"
+
+// requiredTagName is the tag enforcing that a field has a value once its
+// loaders and default ran: required:"true" for any supplied value, even an
+// empty one, or required:"nonzero" to also reject the zero value.
+const requiredTagName = "required"
+
+type requirement int
+
+const (
+	notRequired requirement = iota
+	requireSupplied
+	requireNonZero
+)
+
+// parseRequirement parses the value of a required tag
+func parseRequirement(tag string) (requirement, error) {
+	switch tag {
+	case "nonzero":
+		return requireNonZero, nil
+	case "true":
+		return requireSupplied, nil
+	case "false":
+		return notRequired, nil
+	default:
+		return notRequired, fmt.Errorf("invalid required tag %q: expected true, false or nonzero", tag)
+	}
+}
+
+type fieldPathKey struct{}
+
+// FieldPath returns the struct fields leading from the config struct to the
+// field being loaded, ending with the field itself. Fields of embedded
+// structs are included with the embedded field. It returns nil when ctx does
+// not come from Load.
+func FieldPath(ctx context.Context) []reflect.StructField {
+	path, _ := ctx.Value(fieldPathKey{}).([]reflect.StructField)
+	return path
+}
+
+type loadStateKey struct{}
+
+// loadState is the progress of a Load call, shared with loaders through ctx
+type loadState struct {
+	configValue reflect.Value
+	nodes       map[string]*node
+	order       []string // Field paths in declaration order
+	provenance  Provenance
+}
+
+// PartialConfig returns a pointer to the config struct being loaded, with the
+// fields loaded so far set, or nil when ctx does not come from Load. Loaders
+// must only read the fields they declared as dependencies.
+func PartialConfig(ctx context.Context) any {
+	state, _ := ctx.Value(loadStateKey{}).(*loadState)
+	if state == nil {
+		return nil
+	}
+	return state.configValue.Addr().Interface()
+}
+
+// LookupField returns the value of an already loaded config field by dotted
+// path, as in LookupField(ctx, "Database.Host"), formatted as text. It returns
+// false for fields not loaded yet, and when ctx does not come from Load.
+func LookupField(ctx context.Context, path string) (string, bool) {
+	state, _ := ctx.Value(loadStateKey{}).(*loadState)
+	if state == nil {
+		return "", false
+	}
+
+	if n, exists := state.nodes[path]; !exists || !n.resolved {
+		return "", false
+	}
+
+	field := lookupField(state.configValue, path)
+	if !field.IsValid() {
+		return "", false
+	}
+
+	return formatField(field), true
+}
+
+// ResolveTag resolves the references in a raw tag, or a piece of one, the
+// way Load resolves the tags of other loaders, for loaders implementing
+// RawTagLoader. Literal metacharacters are escaped, see utils.TagMetachars.
+// References fail with ErrUnboundVariable when ctx does not come from Load.
+func ResolveTag(ctx context.Context, tag string) (string, error) {
+	var configValue reflect.Value
+	if state, _ := ctx.Value(loadStateKey{}).(*loadState); state != nil {
+		configValue = state.configValue
+	}
+	return resolveTag(tag, configValue)
+}
+
+// TagReferences returns the dotted paths of the fields a tag references, for
+// loaders implementing RawTagLoader to declare with DependencyDeclarer. It
+// returns a *SyntaxError for malformed tags.
+func TagReferences(tag string) ([]string, error) {
+	return parseTag(tag)
+}
+
+// finalizingKey is the context key for the name of the loader being
+// finalized, which differs from its own name when wrapped by WithTag
+type finalizingKey struct{}
+
+// DeclaredFields returns a request for every field with a tag for the loader
+// being finalized, under the name it was passed to Load with, in declaration
+// order, including fields supplied by another loader and fields skipped by
+// their when tag. Tags are resolved against the fields as loaded so far. It
+// returns nil when ctx does not come from GocfgFinalize.
+func DeclaredFields(ctx context.Context) ([]FieldRequest, error) {
+	state, _ := ctx.Value(loadStateKey{}).(*loadState)
+	name, _ := ctx.Value(finalizingKey{}).(string)
+	if state == nil || name == "" {
+		return nil, nil
+	}
+
+	var requests []FieldRequest
+	for _, fieldName := range state.order {
+		n := state.nodes[fieldName]
+		for _, src := range n.sources {
+			if src.loader.GocfgLoaderName() != name {
+				continue
+			}
+
+			resolvedTag, err := src.resolve(state.configValue)
+			if err != nil {
+				return nil, fmt.Errorf("error resolving tag for %s: %w", n.fieldName, err)
+			}
+
+			requests = append(requests, FieldRequest{
+				Field: n.fieldPath[len(n.fieldPath)-1],
+				Path:  n.fieldPath,
+				Value: state.configValue.FieldByIndex(n.fieldIndex),
+				Tag:   resolvedTag,
+				ctx:   context.WithValue(ctx, fieldPathKey{}, n.fieldPath),
+			})
+		}
+	}
+
+	return requests, nil
+}
+
+// graph is the set of fields to load, keyed by dotted path
+type graph struct {
+	nodes map[string]*node
+	order []string // Field paths in declaration order
+}
+
+// collectFields adds a node for every field of t with a tag for one of the
+// loaders. Untagged struct fields are walked into, and untagged leaf fields
+// are offered to loaders implementing UntaggedLoader. The conditions of
+// enclosing structs apply to every field.
+func (g *graph) collectFields(
+	t reflect.Type, index []int, path []reflect.StructField, prefix string,
+	conditions []condition, loaders []Loader,
+) error {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() && !field.Anonymous {
+			continue
+		}
+
+		fieldIndex := append(append([]int(nil), index...), i)
+		fieldPath := append(append([]reflect.StructField(nil), path...), field)
+
+		name := prefix + field.Name
+
+		var sources []source
+		for _, loader := range loaders {
+			tag := field.Tag.Get(loader.GocfgLoaderName())
+			if tag == "" {
+				continue
+			}
+
+			sources = append(sources, source{tag: tag, loader: loader})
+		}
+
+		defaultTag, hasDefault := field.Tag.Lookup(defaultTagName)
+		exprTag, hasExpr := field.Tag.Lookup(exprTagName)
+
+		fieldConditions := conditions
+		if tag, ok := field.Tag.Lookup(whenTagName); ok {
+			cond, err := parseCondition(tag)
+			if err != nil {
+				return fmt.Errorf("error parsing condition for %s: %w", name, err)
+			}
+			fieldConditions = append(append([]condition(nil), conditions...), cond)
+		}
+
+		if len(sources) == 0 && !hasDefault && !hasExpr && isNested(field.Type) {
+			nestedPrefix := name + "."
+			if field.Anonymous {
+				nestedPrefix = prefix // Fields of embedded structs are promoted, like in Go
+			}
+
+			if err := g.collectFields(
+				field.Type, fieldIndex, fieldPath, nestedPrefix, fieldConditions, loaders,
+			); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if !field.IsExported() {
+			continue
+		}
+
+		if hasExpr && (len(sources) > 0 || hasDefault) {
+			return fmt.Errorf("computed field %s can't also have loader or default tags", name)
+		}
+
+		if len(sources) == 0 && !hasExpr {
+			for _, loader := range loaders {
+				if untagged, ok := loader.(UntaggedLoader); ok {
+					if tag, ok := untagged.GocfgUntaggedTag(field); ok {
+						sources = append(sources, source{tag: tag, loader: loader})
+					}
+				}
+			}
+		}
+
+		if !hasDefault {
+			for _, src := range sources {
+				if defaulter, ok := src.loader.(DefaultLoader); ok {
+					if defaultTag, hasDefault = defaulter.GocfgDefault(field, src.tag); hasDefault {
+						break
+					}
+				}
+			}
+		}
+
+		required := notRequired
+		if tag, ok := field.Tag.Lookup(requiredTagName); ok {
+			var err error
+			if required, err = parseRequirement(tag); err != nil {
+				return fmt.Errorf("error parsing tag for %s: %w", name, err)
+			}
+		}
+
+		if len(sources) == 0 && !hasDefault && !hasExpr && required == notRequired {
+			continue
+		}
+
+		n := &node{
+			fieldName: name, fieldIndex: fieldIndex, fieldPath: fieldPath,
+			required: required, conditions: fieldConditions,
+		}
+		g.nodes[name] = n
+		g.order = append(g.order, name)
+
+		for _, cond := range fieldConditions {
+			n.dependencies = append(n.dependencies, cond.references...)
+		}
+
+		for _, src := range sources {
+			if declarer, ok := src.loader.(DependencyDeclarer); ok {
+				n.dependencies = append(n.dependencies, declarer.GocfgDependencies(field, src.tag)...)
+			}
+
+			if raw, ok := src.loader.(RawTagLoader); ok && raw.GocfgRawTags() {
+				n.sources = append(n.sources, source{tag: src.tag, loader: src.loader, raw: true})
+				continue
+			}
+
+			// Clean whitespace from the tag
+			tag := strings.TrimSpace(src.tag)
+
+			// Parse dependencies from the tag
+			deps, err := parseTag(tag)
+			if err != nil {
+				return fmt.Errorf("error parsing tag for %s: %w", name, err)
+			}
+
+			n.sources = append(n.sources, source{tag: tag, loader: src.loader})
+			n.dependencies = append(n.dependencies, deps...)
+		}
+
+		if hasExpr {
+			e, deps, err := parseExpr(exprTag)
+			if err != nil {
+				return fmt.Errorf("error parsing expression for %s: %w", name, err)
+			}
+
+			n.expr = e
+			n.dependencies = append(n.dependencies, deps...)
+		}
+
+		if hasDefault {
+			deps, err := parseTag(defaultTag)
+			if err != nil {
+				return fmt.Errorf("error parsing default for %s: %w", name, err)
+			}
+
+			n.defaultTag, n.hasDefault = defaultTag, true
+			n.dependencies = append(n.dependencies, deps...)
+		}
+	}
+
+	return nil
+}
+
+// expandDependencies replaces dependencies on nested structs with
+// dependencies on each of their loaded fields, as when a template reads
+// {{.Database}}
+func (g *graph) expandDependencies() {
+	for _, n := range g.nodes {
+		var deps []string
+		for _, dep := range n.dependencies {
+			if _, exists := g.nodes[dep]; exists {
+				deps = append(deps, dep)
+				continue
+			}
+
+			var nested []string
+			for _, name := range g.order {
+				if strings.HasPrefix(name, dep+".") {
+					nested = append(nested, name)
+				}
+			}
+			if len(nested) == 0 {
+				nested = append(nested, dep) // Reported as unbound
+			}
+			deps = append(deps, nested...)
+		}
+		n.dependencies = deps
+	}
+}
+
+// isNested reports whether a field of type t is walked into rather than
+// loaded as a whole: structs that don't decode themselves from text.
+func isNested(t reflect.Type) bool {
+	if t.Kind() != reflect.Struct {
+		return false
+	}
+
+	ptr := reflect.PointerTo(t)
+	return !ptr.Implements(reflect.TypeFor[encoding.TextUnmarshaler]()) &&
+		!ptr.Implements(reflect.TypeFor[encoding.BinaryUnmarshaler]()) &&
+		!ptr.Implements(reflect.TypeFor[json.Unmarshaler]())
+}
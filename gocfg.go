@@ -4,13 +4,14 @@ package gocfg
 import (
 	"context"
 	"errors"
-	"fmt"
 	"reflect"
-	"strings"
 )
 
 type Loader interface {
-	// Load loads values into the field based on the tag
+	// Load loads values into the field based on the tag. Returning an error
+	// wrapping utils.ErrNotFound lets the field's next loader supply it instead.
+	// The tag has its references resolved and its escapes and quotes removed,
+	// unless the loader implements EscapedTagLoader.
 	Load(ctx context.Context, field reflect.StructField, value reflect.Value, resolvedTag string) error
 
 	// Name returns the name of the loader (used for tag lookup)
@@ -18,62 +19,52 @@ type Loader interface {
 }
 
 // Loads configuration into a struct of type C using the provided loaders.
+// When a field has tags for several loaders, they are tried in the order
+// given here, and its default tag is used when none of them has a value.
+// Fields with a cfg tag are computed from other fields instead. Struct
+// fields without tags are walked into, and their fields are referenced by
+// dotted path, as in @Database.Host.
 func Load[C any](ctx context.Context, loaders ...Loader) (config C, err error) {
+	config, _, err = LoadWithProvenance[C](ctx, loaders...)
+	return config, err
+}
+
+// LoadWithProvenance is like Load, also returning where the value of each
+// field came from.
+func LoadWithProvenance[C any](ctx context.Context, loaders ...Loader) (config C, provenance Provenance, err error) {
 
 	// Get type information for the config struct
 	configValue := reflect.ValueOf(&config).Elem()
 	configType := configValue.Type()
 
-	// Map loaders by name for easy lookup
-	loaderMap := make(map[string]Loader)
-	for _, loader := range loaders {
-		loaderMap[loader.GocfgLoaderName()] = loader
-	}
-
-	// Build dependency graph
-	nodes := make(map[string]*node)
-
-	// First pass: discover all fields and their dependencies
-	for i := 0; i < configType.NumField(); i++ {
-		field := configType.Field(i)
-
-		for loaderName, loader := range loaderMap {
-			tag := field.Tag.Get(loaderName)
-			if tag == "" {
-				continue
-			}
-
-			// Clean whitespace from the tag
-			tag = strings.TrimSpace(tag)
-
-			// Parse dependencies from the tag
-			deps, err := parseTag(tag)
-			if err != nil {
-				return config, fmt.Errorf("error parsing tag for %s: %w", field.Name, err)
-			}
-
-			nodes[field.Name] = &node{
-				fieldName:    field.Name,
-				fieldIndex:   i,
-				tag:          tag,
-				loader:       loader,
-				dependencies: deps,
-			}
-
-			break // Only use the first loader that matches
-		}
+	// Build dependency graph, discovering all fields and their dependencies
+	g := &graph{nodes: make(map[string]*node)}
+	if err := g.collectFields(configType, nil, nil, "", nil, loaders); err != nil {
+		return config, nil, err
 	}
+	g.expandDependencies()
+	nodes, order := g.nodes, g.order
 
 	// Check for circular dependencies
 	if err := detectCircularDependencies(nodes); err != nil {
-		return config, err
+		return config, nil, err
 	}
 
-	// Process nodes in dependency order
-	for len(nodes) > 0 {
-		progress := false
+	// Share progress with loaders, for LookupField
+	provenance = make(Provenance)
+	state := &loadState{configValue: configValue, nodes: nodes, order: order, provenance: provenance}
+	ctx = context.WithValue(ctx, loadStateKey{}, state)
+
+	// Process nodes in dependency order, a layer of fields that only depend
+	// on loaded fields at a time
+	for pending := len(order); pending > 0; {
+		var layer []*node
+		for _, fieldName := range order {
+			n := nodes[fieldName]
+			if n.resolved {
+				continue
+			}
 
-		for fieldName, n := range nodes {
 			// Check if all dependencies are resolved
 			allResolved := true
 			for _, dep := range n.dependencies {
@@ -84,34 +75,38 @@ func Load[C any](ctx context.Context, loaders ...Loader) (config C, err error) {
 			}
 
 			if allResolved {
-				progress = true
-
-				field := configType.Field(n.fieldIndex)
-				fieldValue := configValue.Field(n.fieldIndex)
+				layer = append(layer, n)
+			}
+		}
 
-				// Resolve references in the tag
-				resolvedTag, err := resolveTag(n.tag, configValue)
-				if err != nil {
-					return config, fmt.Errorf("error resolving tag for %s: %w", fieldName, err)
-				}
+		if len(layer) == 0 {
+			return config, nil, errors.New("unable to resolve all dependencies, possible circular reference")
+		}
 
-				// Load the value using the appropriate loader
-				if err := n.loader.Load(ctx, field, fieldValue, resolvedTag); err != nil {
-					return config, fmt.Errorf("error loading %s: %w", fieldName, err)
-				}
+		if err := loadLayer(ctx, layer, state); err != nil {
+			return config, nil, err
+		}
 
-				// Mark as resolved and remove from pending nodes
-				n.resolved = true
-				delete(nodes, fieldName)
-			}
+		// Mark as resolved
+		for _, n := range layer {
+			n.resolved = true
 		}
+		pending -= len(layer)
+	}
 
-		if !progress {
-			return config, errors.New("unable to resolve all dependencies, possible circular reference")
+	// Let loaders check the configuration as a whole
+	finalized := make(map[Loader]bool)
+	for _, loader := range loaders {
+		if finalizer, ok := loader.(Finalizer); ok && !finalized[loader] {
+			finalized[loader] = true
+			finalizeCtx := context.WithValue(ctx, finalizingKey{}, loader.GocfgLoaderName())
+			if err := finalizer.GocfgFinalize(finalizeCtx); err != nil {
+				return config, nil, err
+			}
 		}
 	}
 
-	return config, nil
+	return config, provenance, nil
 }
 
 func MustLoad[C any](ctx context.Context, loaders ...Loader) C {
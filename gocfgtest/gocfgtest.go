@@ -0,0 +1,193 @@
+// Package gocfgtest provides a fake loader for testing code that loads
+// configuration with gocfg, without changing the environment or calling
+// remote backends.
+package gocfgtest
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Gardego5/gocfg"
+	"github.com/Gardego5/gocfg/loaders/static"
+	"github.com/Gardego5/gocfg/utils"
+)
+
+// Call is a field loaded by a Loader
+type Call struct {
+	Field string // Dotted path of the field, as in Database.Host
+	Tag   string // The resolved tag, without escapes
+}
+
+// Loader is a fake loader reading the tags of another loader, such as "env"
+// or "aws/secretsmanager". Values are looked up like static loader keys, so
+// a field tagged `env:"DB_HOST?"` reads the value for DB_HOST, but tags with
+// options of their own are keyed whole, as in "DB_PASSWORD,file", unless
+// WithKey derives the keys. Every load is recorded for assertions.
+type Loader struct {
+	name string
+	key  func(tag string) (key string, optional bool)
+
+	mu     sync.Mutex
+	values map[string]string
+	calls  []Call
+}
+
+// Option configures a Loader
+type Option func(*Loader)
+
+// WithKey looks values up by the key that key derives from each resolved tag,
+// written without escapes, in place of the static loader's syntax. Fields
+// whose key has no value are missing, and required unless key reports them
+// optional. For env tags with options:
+//
+//	gocfgtest.WithKey(func(tag string) (string, bool) {
+//		name, _, _ := strings.Cut(tag, ",")
+//		return strings.TrimSuffix(name, "?"), strings.HasSuffix(tag, "?")
+//	})
+func WithKey(key func(tag string) (key string, optional bool)) Option {
+	return func(l *Loader) { l.key = key }
+}
+
+// NewLoader returns a fake loader for the tag name, with the given values
+func NewLoader(name string, values map[string]string, opts ...Option) *Loader {
+	l := &Loader{name: name, values: make(map[string]string, len(values))}
+	for key, value := range values {
+		l.values[key] = value
+	}
+
+	for _, opt := range opts {
+		opt(l)
+	}
+
+	return l
+}
+
+func (l *Loader) GocfgLoaderName() string { return l.name }
+func (*Loader) GocfgEscapedTags() bool    { return true }
+
+// Load implements the Loader interface, recording the call
+func (l *Loader) Load(
+	ctx context.Context,
+	field reflect.StructField, value reflect.Value,
+	resolvedTag string,
+) error {
+	call := Call{Field: fieldName(gocfg.FieldPath(ctx), field), Tag: utils.UnescapeTag(resolvedTag)}
+
+	l.mu.Lock()
+	l.calls = append(l.calls, call)
+	values := static.New(l.values)
+	l.mu.Unlock()
+
+	if l.key == nil {
+		return values.Load(ctx, field, value, resolvedTag)
+	}
+
+	key, optional := l.key(call.Tag)
+	text, exists := l.lookup(key)
+	if !exists {
+		return utils.NotFoundError(optional, "key %s not found", key)
+	}
+	return utils.SetFieldValue(value, text)
+}
+
+func (l *Loader) lookup(key string) (string, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	text, exists := l.values[key]
+	return text, exists
+}
+
+// Set sets the value for key for the rest of the test, like t.Setenv does
+// for environment variables. The previous value is restored when the test
+// ends.
+func (l *Loader) Set(t testing.TB, key, value string) {
+	t.Helper()
+
+	l.mu.Lock()
+	previous, existed := l.values[key]
+	l.values[key] = value
+	l.mu.Unlock()
+
+	t.Cleanup(func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+
+		if existed {
+			l.values[key] = previous
+		} else {
+			delete(l.values, key)
+		}
+	})
+}
+
+// Calls returns the fields loaded so far, in order
+func (l *Loader) Calls() []Call {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]Call(nil), l.calls...)
+}
+
+// Reset forgets the recorded calls
+func (l *Loader) Reset() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.calls = nil
+}
+
+// AssertLoaded reports a test error unless field was loaded with the resolved
+// tag, written without escapes, and returns whether it was
+func (l *Loader) AssertLoaded(t testing.TB, field, resolvedTag string) bool {
+	t.Helper()
+
+	var tags []string
+	for _, call := range l.Calls() {
+		if call.Field != field {
+			continue
+		}
+		if call.Tag == resolvedTag {
+			return true
+		}
+		tags = append(tags, call.Tag)
+	}
+
+	if len(tags) == 0 {
+		t.Errorf("%s loader: expected %s to be loaded with tag %q, but it was not loaded", l.name, field, resolvedTag)
+	} else {
+		t.Errorf("%s loader: expected %s to be loaded with tag %q, but it was loaded with %q", l.name, field, resolvedTag, tags)
+	}
+	return false
+}
+
+// AssertNotLoaded reports a test error if field was loaded, and returns
+// whether it wasn't
+func (l *Loader) AssertNotLoaded(t testing.TB, field string) bool {
+	t.Helper()
+
+	for _, call := range l.Calls() {
+		if call.Field == field {
+			t.Errorf("%s loader: expected %s not to be loaded, but it was loaded with tag %q", l.name, field, call.Tag)
+			return false
+		}
+	}
+	return true
+}
+
+// fieldName returns the dotted path of a field. Embedded structs are left
+// out, as their fields are promoted.
+func fieldName(path []reflect.StructField, field reflect.StructField) string {
+	if len(path) == 0 {
+		return field.Name
+	}
+
+	var names []string
+	for i, f := range path {
+		if !f.Anonymous || i == len(path)-1 {
+			names = append(names, f.Name)
+		}
+	}
+	return strings.Join(names, ".")
+}
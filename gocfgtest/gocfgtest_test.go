@@ -0,0 +1,148 @@
+package gocfgtest_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	. "github.com/Gardego5/gocfg"
+	"github.com/Gardego5/gocfg/gocfgtest"
+	"github.com/Gardego5/gocfg/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingT records the errors reported by assertions
+type recordingT struct {
+	testing.TB
+	errors []string
+}
+
+func (*recordingT) Helper() {}
+
+func (r *recordingT) Errorf(format string, args ...any) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+type Database struct {
+	Host string `env:"DB_HOST"`
+	Port int    `env:"DB_PORT=5432"`
+}
+
+type appConfig struct {
+	Database
+	Tenant string `env:"TENANT?"`
+	Secret string `aws/secretsmanager:"@Tenant||/app:secret" default:"none"`
+}
+
+func TestLoader(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Loads and records fields", func(t *testing.T) {
+		env := gocfgtest.NewLoader("env", map[string]string{"DB_HOST": "db.internal", "TENANT": "acme"})
+		secrets := gocfgtest.NewLoader("aws/secretsmanager", map[string]string{"acme/app:secret": "hunter2"})
+
+		result, err := Load[appConfig](ctx, env, secrets)
+
+		require.NoError(t, err)
+		assert.Equal(t, "db.internal", result.Host)
+		assert.Equal(t, 5432, result.Port)
+		assert.Equal(t, "hunter2", result.Secret)
+
+		assert.Equal(t, []gocfgtest.Call{
+			{Field: "Host", Tag: "DB_HOST"},
+			{Field: "Port", Tag: "DB_PORT=5432"},
+			{Field: "Tenant", Tag: "TENANT?"},
+		}, env.Calls())
+		secrets.AssertLoaded(t, "Secret", "acme/app:secret")
+	})
+
+	t.Run("Records tags without escapes", func(t *testing.T) {
+		env := gocfgtest.NewLoader("env", map[string]string{"DB_HOST": "db.internal", "TENANT": "acme:eu"})
+		secrets := gocfgtest.NewLoader("aws/secretsmanager", map[string]string{"acme:eu/app:secret": "hunter2"})
+
+		result, err := Load[appConfig](ctx, env, secrets)
+
+		require.NoError(t, err)
+		assert.Equal(t, "hunter2", result.Secret)
+		secrets.AssertLoaded(t, "Secret", "acme:eu/app:secret")
+	})
+
+	t.Run("Keys tags with options whole", func(t *testing.T) {
+		env := gocfgtest.NewLoader("env", map[string]string{"DB_PASSWORD,file": "hunter2"})
+
+		result, err := Load[struct {
+			Password string `env:"DB_PASSWORD,file"`
+		}](ctx, env)
+
+		require.NoError(t, err)
+		assert.Equal(t, "hunter2", result.Password)
+	})
+
+	t.Run("Derives keys with WithKey", func(t *testing.T) {
+		env := gocfgtest.NewLoader("env", map[string]string{"DB_PASSWORD": "hunter2"},
+			gocfgtest.WithKey(func(tag string) (string, bool) {
+				name, _, _ := strings.Cut(tag, ",")
+				return strings.TrimSuffix(name, "?"), strings.HasSuffix(tag, "?")
+			}),
+		)
+
+		result, err := Load[struct {
+			Password string `env:"DB_PASSWORD,file"`
+			Token    string `env:"API_TOKEN,file?"`
+		}](ctx, env)
+
+		require.NoError(t, err)
+		assert.Equal(t, "hunter2", result.Password)
+		assert.Empty(t, result.Token)
+		env.AssertLoaded(t, "Password", "DB_PASSWORD,file")
+
+		_, err = Load[struct {
+			Token string `env:"API_TOKEN,file"`
+		}](ctx, env)
+		require.ErrorIs(t, err, utils.ErrMissingRequired)
+	})
+
+	t.Run("Overrides values for the rest of the test", func(t *testing.T) {
+		env := gocfgtest.NewLoader("env", map[string]string{"DB_HOST": "db.internal"})
+
+		t.Run("Override", func(t *testing.T) {
+			env.Set(t, "DB_HOST", "override")
+			env.Set(t, "TENANT", "acme")
+
+			result, err := Load[appConfig](ctx, env)
+			require.NoError(t, err)
+			assert.Equal(t, "override", result.Host)
+			assert.Equal(t, "acme", result.Tenant)
+		})
+
+		result, err := Load[appConfig](ctx, env)
+		require.NoError(t, err)
+		assert.Equal(t, "db.internal", result.Host)
+		assert.Empty(t, result.Tenant)
+	})
+
+	t.Run("Reports unexpected loads", func(t *testing.T) {
+		env := gocfgtest.NewLoader("env", map[string]string{"DB_HOST": "db.internal"})
+		_, err := Load[appConfig](ctx, env)
+		require.NoError(t, err)
+
+		recorder := &recordingT{}
+		assert.True(t, env.AssertLoaded(recorder, "Host", "DB_HOST"))
+		assert.True(t, env.AssertNotLoaded(recorder, "Secret"))
+		assert.Empty(t, recorder.errors)
+
+		assert.False(t, env.AssertLoaded(recorder, "Host", "OTHER_HOST"))
+		assert.False(t, env.AssertLoaded(recorder, "Secret", "SECRET"))
+		assert.False(t, env.AssertNotLoaded(recorder, "Port"))
+		assert.Equal(t, []string{
+			`env loader: expected Host to be loaded with tag "OTHER_HOST", but it was loaded with ["DB_HOST"]`,
+			`env loader: expected Secret to be loaded with tag "SECRET", but it was not loaded`,
+			`env loader: expected Port not to be loaded, but it was loaded with tag "DB_PORT=5432"`,
+		}, recorder.errors)
+
+		env.Reset()
+		assert.Empty(t, env.Calls())
+	})
+}
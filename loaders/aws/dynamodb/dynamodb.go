@@ -0,0 +1,498 @@
+package dynamodb
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Gardego5/gocfg"
+	"github.com/Gardego5/gocfg/utils"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// maxBatchKeys is the most items BatchGetItem reads per call
+const maxBatchKeys = 100
+
+// maxUnprocessedRetries bounds how many times unprocessed keys returned by
+// BatchGetItem are requested again before giving up.
+const maxUnprocessedRetries = 5
+
+// unprocessedBackoff is the wait before requesting unprocessed keys again,
+// doubling with every retry
+const unprocessedBackoff = 50 * time.Millisecond
+
+type dynamoDBClient interface {
+	BatchGetItem(
+		ctx context.Context,
+		params *dynamodb.BatchGetItemInput,
+		optFns ...func(*dynamodb.Options),
+	) (*dynamodb.BatchGetItemOutput, error)
+
+	DescribeTable(
+		ctx context.Context,
+		params *dynamodb.DescribeTableInput,
+		optFns ...func(*dynamodb.Options),
+	) (*dynamodb.DescribeTableOutput, error)
+}
+
+// DynamoDBLoader loads configuration from items in AWS DynamoDB tables
+func New(client ...dynamoDBClient) gocfg.Loader {
+	var c dynamoDBClient
+	switch len(client) {
+	case 0:
+		awsConfig, err := config.LoadDefaultConfig(context.Background())
+		if err != nil {
+			panic(err)
+		}
+
+		c = dynamodb.NewFromConfig(awsConfig)
+
+	case 1:
+		c = client[0]
+
+	default:
+		panic("too many arguments")
+	}
+
+	return &loader{client: c, schemas: make(map[string]keySchema)}
+}
+
+// keySchema describes the primary key attributes of a table
+type keySchema struct {
+	partitionKey, partitionType string
+	sortKey, sortType           string
+}
+
+// item is a fetched item, or a record that the item does not exist
+type item struct {
+	attributes map[string]types.AttributeValue
+	exists     bool
+}
+
+type loader struct {
+	client dynamoDBClient
+
+	mu      sync.Mutex
+	schemas map[string]keySchema
+}
+
+func (*loader) GocfgLoaderName() string { return "aws/dynamodb" }
+func (*loader) GocfgEscapedTags() bool  { return true }
+
+// Load implements the Loader interface for AWS DynamoDB
+// Tag formats supported:
+// - "table/partitionKey:attribute" - Get an attribute from an item
+// - "table/partitionKey/sortKey:attribute" - Get an attribute from an item in
+// a table with a sort key
+// - "table/partitionKey" - Use the field name as the attribute
+// - "table/partitionKey:attribute?" - Optional item or attribute
+// - "tenants/||@TenantID||:attribute" - Build the key from other fields
+//
+// Fields loaded together share BatchGetItem round trips, each reading up to
+// 100 items, and each item is read once however many fields target it. Items
+// are read again on every Load, so changes to the table are picked up.
+func (l *loader) Load(
+	ctx context.Context,
+	field reflect.StructField, value reflect.Value,
+	resolvedTag string,
+) error {
+	ref, err := parseTag(field, resolvedTag)
+	if err != nil {
+		return err
+	}
+
+	fetched := l.getItems(ctx, []itemRef{ref})[ref.id()]
+	return ref.set(value, fetched.item, fetched.err)
+}
+
+// LoadBatch implements gocfg.BatchLoader, reading the items of all fields
+// with as few BatchGetItem calls as possible
+func (l *loader) LoadBatch(ctx context.Context, requests []gocfg.FieldRequest) []error {
+	errs := make([]error, len(requests))
+	refs := make([]itemRef, len(requests))
+
+	var valid []itemRef
+	for i, request := range requests {
+		if refs[i], errs[i] = parseTag(request.Field, request.Tag); errs[i] == nil {
+			valid = append(valid, refs[i])
+		}
+	}
+
+	items := l.getItems(ctx, valid)
+
+	for i, request := range requests {
+		if errs[i] == nil {
+			fetched := items[refs[i].id()]
+			errs[i] = refs[i].set(request.Value, fetched.item, fetched.err)
+		}
+	}
+
+	return errs
+}
+
+// itemRef is a parsed tag
+type itemRef struct {
+	table     string
+	keyValues []string
+	attribute string
+	optional  bool
+}
+
+// parseTag parses the tag of a field
+func parseTag(field reflect.StructField, resolvedTag string) (ref itemRef, err error) {
+	// Handle special case - fully resolved reference or concatenation
+	if strings.HasPrefix(resolvedTag, "@") || strings.Contains(resolvedTag, "||") {
+		// At this point the tag should be resolved already
+		return ref, fmt.Errorf("unexpected unresolved tag: %s", resolvedTag)
+	}
+
+	tag := strings.TrimSpace(resolvedTag)
+
+	// Check if the attribute is optional
+	tag, ref.optional = utils.CutTagSuffix(tag, "?")
+
+	// Split the item key from the attribute, defaulting to the field name
+	itemKey, attribute, hasAttribute := utils.CutTag(tag, ":")
+	if !hasAttribute {
+		attribute = field.Name
+	}
+	ref.attribute = utils.UnescapeTag(strings.TrimSpace(attribute))
+
+	parts := utils.SplitTag(strings.TrimSpace(itemKey), "/")
+	if len(parts) < 2 || len(parts) > 3 || parts[0] == "" || parts[1] == "" {
+		return ref, utils.InvalidTagError("invalid item key %q: expected table/partitionKey[/sortKey]", itemKey)
+	}
+	for i, part := range parts {
+		parts[i] = utils.UnescapeTag(part)
+	}
+	ref.table, ref.keyValues = parts[0], parts[1:]
+
+	return ref, nil
+}
+
+// id identifies the referenced item
+func (ref itemRef) id() string {
+	return ref.table + "/" + strings.Join(ref.keyValues, "/")
+}
+
+// set sets a field from the referenced attribute of an item, or reports the
+// error reading the item
+func (ref itemRef) set(value reflect.Value, it item, err error) error {
+	if err != nil {
+		return err
+	}
+
+	if !it.exists {
+		return utils.NotFoundError(ref.optional, "item %s not found", ref.id())
+	}
+
+	attributeValue, exists := it.attributes[ref.attribute]
+	if !exists {
+		return utils.NotFoundError(ref.optional, "attribute %s not found in item %s", ref.attribute, ref.id())
+	}
+
+	return setFieldAttribute(value, attributeValue)
+}
+
+// fetchedItem is an item, or the error reading it
+type fetchedItem struct {
+	item item
+	err  error
+}
+
+// getItems reads the referenced items with BatchGetItem, up to maxBatchKeys
+// per call, returning them by id
+func (l *loader) getItems(ctx context.Context, refs []itemRef) map[string]fetchedItem {
+	items := make(map[string]fetchedItem, len(refs))
+
+	// pendingKey is an item still to read
+	type pendingKey struct {
+		id, table string
+		key       map[string]types.AttributeValue
+	}
+
+	var pending []pendingKey
+	ids := make(map[string]string) // Item ids by table and identity
+	for _, ref := range refs {
+		id := ref.id()
+		if _, seen := items[id]; seen {
+			continue
+		}
+
+		schema, err := l.getKeySchema(ctx, ref.table)
+		if err != nil {
+			items[id] = fetchedItem{err: err}
+			continue
+		}
+
+		key, err := schema.key(ref.keyValues)
+		if err != nil {
+			items[id] = fetchedItem{err: utils.InvalidTagError("invalid key for table %s: %w", ref.table, err)}
+			continue
+		}
+
+		items[id] = fetchedItem{} // Until read
+		ids[ref.table+"\x00"+schema.identity(key)] = id
+		pending = append(pending, pendingKey{id: id, table: ref.table, key: key})
+	}
+
+	for start := 0; start < len(pending); start += maxBatchKeys {
+		chunk := pending[start:min(start+maxBatchKeys, len(pending))]
+
+		request := make(map[string]types.KeysAndAttributes)
+		for _, p := range chunk {
+			keys := request[p.table]
+			keys.Keys = append(keys.Keys, p.key)
+			request[p.table] = keys
+		}
+
+		var err error
+		for attempt := 0; len(request) > 0; attempt++ {
+			if attempt > maxUnprocessedRetries {
+				err = errors.New("keys remained unprocessed")
+				break
+			}
+			if attempt > 0 {
+				if err = wait(ctx, unprocessedBackoff<<(attempt-1)); err != nil {
+					break
+				}
+			}
+
+			var result *dynamodb.BatchGetItemOutput
+			if result, err = l.client.BatchGetItem(ctx, &dynamodb.BatchGetItemInput{RequestItems: request}); err != nil {
+				break
+			}
+
+			for table, responses := range result.Responses {
+				schema, _ := l.getKeySchema(ctx, table)
+				for _, attributes := range responses {
+					if id, exists := ids[table+"\x00"+schema.identity(attributes)]; exists {
+						items[id] = fetchedItem{item: item{attributes: attributes, exists: true}}
+					}
+				}
+			}
+
+			request = result.UnprocessedKeys
+		}
+
+		// Items read before the error are kept, only the keys still to read
+		// fail
+		if err != nil {
+			for table, keys := range request {
+				schema, _ := l.getKeySchema(ctx, table)
+				for _, key := range keys.Keys {
+					if id, exists := ids[table+"\x00"+schema.identity(key)]; exists {
+						items[id] = fetchedItem{err: fmt.Errorf("failed to retrieve item %s: %w", id, err)}
+					}
+				}
+			}
+		}
+	}
+
+	return items
+}
+
+// wait waits for d, or until ctx is done
+func wait(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// getKeySchema returns the primary key schema of a table, describing the
+// table the first time it is requested.
+func (l *loader) getKeySchema(ctx context.Context, table string) (keySchema, error) {
+	l.mu.Lock()
+	schema, isCached := l.schemas[table]
+	l.mu.Unlock()
+	if isCached {
+		return schema, nil
+	}
+
+	result, err := l.client.DescribeTable(ctx, &dynamodb.DescribeTableInput{
+		TableName: aws.String(table),
+	})
+	if err != nil {
+		return keySchema{}, fmt.Errorf("failed to describe table %s: %w", table, err)
+	}
+	if result.Table == nil {
+		return keySchema{}, fmt.Errorf("empty description returned for table %s", table)
+	}
+
+	attributeTypes := make(map[string]string)
+	for _, definition := range result.Table.AttributeDefinitions {
+		attributeTypes[aws.ToString(definition.AttributeName)] = string(definition.AttributeType)
+	}
+
+	for _, element := range result.Table.KeySchema {
+		name := aws.ToString(element.AttributeName)
+		switch element.KeyType {
+		case types.KeyTypeHash:
+			schema.partitionKey, schema.partitionType = name, attributeTypes[name]
+		case types.KeyTypeRange:
+			schema.sortKey, schema.sortType = name, attributeTypes[name]
+		}
+	}
+
+	l.mu.Lock()
+	l.schemas[table] = schema
+	l.mu.Unlock()
+
+	return schema, nil
+}
+
+// key builds the primary key of an item from the values in a tag
+func (s keySchema) key(values []string) (map[string]types.AttributeValue, error) {
+	if s.sortKey == "" && len(values) != 1 {
+		return nil, errors.New("table has no sort key")
+	}
+	if s.sortKey != "" && len(values) != 2 {
+		return nil, fmt.Errorf("table requires a value for sort key %s", s.sortKey)
+	}
+
+	partitionValue, err := keyAttribute(s.partitionType, values[0])
+	if err != nil {
+		return nil, err
+	}
+	key := map[string]types.AttributeValue{s.partitionKey: partitionValue}
+
+	if s.sortKey != "" {
+		sortValue, err := keyAttribute(s.sortType, values[1])
+		if err != nil {
+			return nil, err
+		}
+		key[s.sortKey] = sortValue
+	}
+
+	return key, nil
+}
+
+// identity returns the key of an item as text, to match the items
+// BatchGetItem returns with the keys requested. Numbers are compared by value,
+// as DynamoDB returns them normalized.
+func (s keySchema) identity(attributes map[string]types.AttributeValue) string {
+	identity := keyText(attributes[s.partitionKey])
+	if s.sortKey != "" {
+		identity += "\x00" + keyText(attributes[s.sortKey])
+	}
+	return identity
+}
+
+// keyText returns a key attribute as text
+func keyText(attributeValue types.AttributeValue) string {
+	switch v := attributeValue.(type) {
+	case *types.AttributeValueMemberS:
+		return "S" + v.Value
+	case *types.AttributeValueMemberN:
+		if number, ok := new(big.Rat).SetString(v.Value); ok {
+			return "N" + number.RatString()
+		}
+		return "N" + v.Value
+	case *types.AttributeValueMemberB:
+		return "B" + base64.StdEncoding.EncodeToString(v.Value)
+	}
+	return ""
+}
+
+// keyAttribute converts a key value from a tag to an attribute of the given
+// scalar type
+func keyAttribute(attributeType, value string) (types.AttributeValue, error) {
+	switch types.ScalarAttributeType(attributeType) {
+	case types.ScalarAttributeTypeN:
+		return &types.AttributeValueMemberN{Value: value}, nil
+	case types.ScalarAttributeTypeB:
+		bytes, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return nil, fmt.Errorf("binary key value must be base64 encoded: %w", err)
+		}
+		return &types.AttributeValueMemberB{Value: bytes}, nil
+	default:
+		return &types.AttributeValueMemberS{Value: value}, nil
+	}
+}
+
+// setFieldAttribute sets the field from an attribute value. Scalars are set
+// from their text, while maps, lists and sets are decoded as JSON.
+func setFieldAttribute(fieldValue reflect.Value, attributeValue types.AttributeValue) error {
+	var stringValue string
+	switch v := attributeValue.(type) {
+	case *types.AttributeValueMemberS:
+		stringValue = v.Value
+	case *types.AttributeValueMemberN:
+		stringValue = v.Value
+	case *types.AttributeValueMemberBOOL:
+		stringValue = fmt.Sprintf("%t", v.Value)
+	case *types.AttributeValueMemberB:
+		stringValue = string(v.Value)
+	case *types.AttributeValueMemberNULL:
+		stringValue = ""
+	default:
+		// For complex types, encode as JSON
+		bytes, err := json.Marshal(toInterface(v))
+		if err != nil {
+			return fmt.Errorf("failed to marshal complex attribute value: %w", err)
+		}
+
+		switch fieldValue.Kind() {
+		case reflect.Struct, reflect.Map, reflect.Slice, reflect.Array:
+			return json.Unmarshal(bytes, fieldValue.Addr().Interface())
+		}
+		stringValue = string(bytes)
+	}
+
+	return utils.SetFieldValue(fieldValue, stringValue)
+}
+
+// toInterface converts an attribute value into the equivalent plain Go value
+func toInterface(attributeValue types.AttributeValue) any {
+	switch v := attributeValue.(type) {
+	case *types.AttributeValueMemberS:
+		return v.Value
+	case *types.AttributeValueMemberN:
+		return json.Number(v.Value)
+	case *types.AttributeValueMemberBOOL:
+		return v.Value
+	case *types.AttributeValueMemberB:
+		return v.Value
+	case *types.AttributeValueMemberSS:
+		return v.Value
+	case *types.AttributeValueMemberNS:
+		numbers := make([]json.Number, len(v.Value))
+		for i, n := range v.Value {
+			numbers[i] = json.Number(n)
+		}
+		return numbers
+	case *types.AttributeValueMemberBS:
+		return v.Value
+	case *types.AttributeValueMemberL:
+		list := make([]any, len(v.Value))
+		for i, element := range v.Value {
+			list[i] = toInterface(element)
+		}
+		return list
+	case *types.AttributeValueMemberM:
+		m := make(map[string]any, len(v.Value))
+		for key, element := range v.Value {
+			m[key] = toInterface(element)
+		}
+		return m
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,368 @@
+package dynamodb_test
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	. "github.com/Gardego5/gocfg"
+	. "github.com/Gardego5/gocfg/loaders/aws/dynamodb"
+	"github.com/Gardego5/gocfg/loaders/env"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// MockTable is a table with a key schema and items keyed by their key values
+type MockTable struct {
+	PartitionKey, PartitionType string
+	SortKey, SortType           string
+	Items                       map[string]map[string]types.AttributeValue // "pk" or "pk/sk" to item
+}
+
+// MockDynamoDBClient implements a mock for AWS DynamoDB client
+type MockDynamoDBClient struct {
+	Tables          map[string]MockTable
+	BatchGetCalls   int
+	MaxKeys         int             // Most keys requested in one call
+	UnprocessedOnce bool            // Report every key as unprocessed on the first call
+	Throttled       map[string]bool // Keys, as "table/pk", always reported unprocessed
+}
+
+// BatchGetItem implements the DynamoDB BatchGetItem operation
+func (m *MockDynamoDBClient) BatchGetItem(
+	ctx context.Context,
+	params *dynamodb.BatchGetItemInput,
+	optFns ...func(*dynamodb.Options),
+) (*dynamodb.BatchGetItemOutput, error) {
+	m.BatchGetCalls++
+
+	keys := 0
+	for _, request := range params.RequestItems {
+		keys += len(request.Keys)
+	}
+	m.MaxKeys = max(m.MaxKeys, keys)
+
+	if m.UnprocessedOnce {
+		m.UnprocessedOnce = false
+		return &dynamodb.BatchGetItemOutput{UnprocessedKeys: params.RequestItems}, nil
+	}
+
+	responses := make(map[string][]map[string]types.AttributeValue)
+	unprocessed := make(map[string]types.KeysAndAttributes)
+	for tableName, request := range params.RequestItems {
+		table := m.Tables[tableName]
+		for _, key := range request.Keys {
+			values := []string{keyString(key[table.PartitionKey])}
+			if table.SortKey != "" {
+				values = append(values, keyString(key[table.SortKey]))
+			}
+			if m.Throttled[tableName+"/"+strings.Join(values, "/")] {
+				keys := unprocessed[tableName]
+				keys.Keys = append(keys.Keys, key)
+				unprocessed[tableName] = keys
+				continue
+			}
+			if item, exists := table.Items[strings.Join(values, "/")]; exists {
+				responses[tableName] = append(responses[tableName], item)
+			}
+		}
+	}
+
+	return &dynamodb.BatchGetItemOutput{Responses: responses, UnprocessedKeys: unprocessed}, nil
+}
+
+// DescribeTable implements the DynamoDB DescribeTable operation
+func (m *MockDynamoDBClient) DescribeTable(
+	ctx context.Context,
+	params *dynamodb.DescribeTableInput,
+	optFns ...func(*dynamodb.Options),
+) (*dynamodb.DescribeTableOutput, error) {
+	table, exists := m.Tables[aws.ToString(params.TableName)]
+	if !exists {
+		return nil, &types.ResourceNotFoundException{Message: params.TableName}
+	}
+
+	description := &types.TableDescription{
+		KeySchema: []types.KeySchemaElement{
+			{AttributeName: aws.String(table.PartitionKey), KeyType: types.KeyTypeHash},
+		},
+		AttributeDefinitions: []types.AttributeDefinition{
+			{AttributeName: aws.String(table.PartitionKey), AttributeType: types.ScalarAttributeType(table.PartitionType)},
+		},
+	}
+	if table.SortKey != "" {
+		description.KeySchema = append(description.KeySchema,
+			types.KeySchemaElement{AttributeName: aws.String(table.SortKey), KeyType: types.KeyTypeRange})
+		description.AttributeDefinitions = append(description.AttributeDefinitions,
+			types.AttributeDefinition{AttributeName: aws.String(table.SortKey), AttributeType: types.ScalarAttributeType(table.SortType)})
+	}
+
+	return &dynamodb.DescribeTableOutput{Table: description}, nil
+}
+
+func keyString(value types.AttributeValue) string {
+	switch v := value.(type) {
+	case *types.AttributeValueMemberS:
+		return v.Value
+	case *types.AttributeValueMemberN:
+		return v.Value
+	}
+	return ""
+}
+
+func setupMockClient() *MockDynamoDBClient {
+	// Initialize mock client with predefined tables
+	return &MockDynamoDBClient{
+		Tables: map[string]MockTable{
+			"tenants": {
+				PartitionKey: "TenantID", PartitionType: "S",
+				Items: map[string]map[string]types.AttributeValue{
+					"acme": {
+						"TenantID": &types.AttributeValueMemberS{Value: "acme"},
+						"MaxUsers": &types.AttributeValueMemberN{Value: "25"},
+						"Enabled":  &types.AttributeValueMemberBOOL{Value: true},
+						"Plan":     &types.AttributeValueMemberS{Value: "enterprise"},
+						"Limits": &types.AttributeValueMemberM{Value: map[string]types.AttributeValue{
+							"requests": &types.AttributeValueMemberN{Value: "100"},
+							"regions":  &types.AttributeValueMemberSS{Value: []string{"us-east-1", "eu-west-1"}},
+						}},
+					},
+				},
+			},
+			"settings": {
+				PartitionKey: "Shard", PartitionType: "N",
+				SortKey: "Name", SortType: "S",
+				Items: map[string]map[string]types.AttributeValue{
+					"7/cache": {
+						"Shard": &types.AttributeValueMemberN{Value: "7"},
+						"Name":  &types.AttributeValueMemberS{Value: "cache"},
+						"TTL":   &types.AttributeValueMemberN{Value: "300"},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestDynamoDBLoader(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Loads attributes from an item", func(t *testing.T) {
+		result, err := Load[struct {
+			MaxUsers int    `aws/dynamodb:"tenants/acme:MaxUsers"`
+			Enabled  bool   `aws/dynamodb:"tenants/acme:Enabled"`
+			Tier     string `aws/dynamodb:"tenants/acme:Plan"`
+		}](ctx, New(setupMockClient()))
+
+		require.NoError(t, err)
+		assert.Equal(t, 25, result.MaxUsers)
+		assert.True(t, result.Enabled)
+		assert.Equal(t, "enterprise", result.Tier)
+	})
+
+	t.Run("Uses field name as attribute when none specified", func(t *testing.T) {
+		result, err := Load[struct {
+			Plan string `aws/dynamodb:"tenants/acme"`
+		}](ctx, New(setupMockClient()))
+
+		require.NoError(t, err)
+		assert.Equal(t, "enterprise", result.Plan)
+	})
+
+	t.Run("Loads items from tables with a sort key", func(t *testing.T) {
+		result, err := Load[struct {
+			TTL int `aws/dynamodb:"settings/7/cache:TTL"`
+		}](ctx, New(setupMockClient()))
+
+		require.NoError(t, err)
+		assert.Equal(t, 300, result.TTL)
+	})
+
+	t.Run("Decodes map attributes into structs", func(t *testing.T) {
+		result, err := Load[struct {
+			Limits struct {
+				Requests int      `json:"requests"`
+				Regions  []string `json:"regions"`
+			} `aws/dynamodb:"tenants/acme"`
+		}](ctx, New(setupMockClient()))
+
+		require.NoError(t, err)
+		assert.Equal(t, 100, result.Limits.Requests)
+		assert.Equal(t, []string{"us-east-1", "eu-west-1"}, result.Limits.Regions)
+	})
+
+	t.Run("Reads each item once for all fields targeting it", func(t *testing.T) {
+		client := setupMockClient()
+
+		_, err := Load[struct {
+			MaxUsers int    `aws/dynamodb:"tenants/acme:MaxUsers"`
+			Enabled  bool   `aws/dynamodb:"tenants/acme:Enabled"`
+			Plan     string `aws/dynamodb:"tenants/acme"`
+		}](ctx, New(client))
+
+		require.NoError(t, err)
+		assert.Equal(t, 1, client.BatchGetCalls)
+	})
+
+	t.Run("Reads the items of all fields in one call", func(t *testing.T) {
+		client := setupMockClient()
+
+		result, err := Load[struct {
+			Plan string `aws/dynamodb:"tenants/acme"`
+			TTL  int    `aws/dynamodb:"settings/7/cache:TTL"`
+			None string `aws/dynamodb:"tenants/missing:Plan?"`
+		}](ctx, New(client))
+
+		require.NoError(t, err)
+		assert.Equal(t, "enterprise", result.Plan)
+		assert.Equal(t, 300, result.TTL)
+		assert.Equal(t, 1, client.BatchGetCalls)
+	})
+
+	t.Run("Reads at most 100 items per call", func(t *testing.T) {
+		client := setupMockClient()
+		table := client.Tables["tenants"]
+		loader := New(client).(BatchLoader)
+
+		type config struct{ Plan string }
+		values := make([]config, 150)
+		requests := make([]FieldRequest, len(values))
+		for i := range values {
+			id := fmt.Sprintf("tenant-%d", i)
+			table.Items[id] = map[string]types.AttributeValue{
+				"TenantID": &types.AttributeValueMemberS{Value: id},
+				"Plan":     &types.AttributeValueMemberS{Value: id},
+			}
+
+			value := reflect.ValueOf(&values[i]).Elem()
+			requests[i] = FieldRequest{
+				Field: value.Type().Field(0),
+				Value: value.Field(0),
+				Tag:   "tenants/" + id,
+			}
+		}
+
+		for _, err := range loader.LoadBatch(ctx, requests) {
+			require.NoError(t, err)
+		}
+		assert.Equal(t, "tenant-149", values[149].Plan)
+		assert.Equal(t, 2, client.BatchGetCalls)
+		assert.Equal(t, 100, client.MaxKeys)
+	})
+
+	t.Run("Reads items again on every load", func(t *testing.T) {
+		client := setupMockClient()
+		loader := New(client)
+
+		type config struct {
+			Plan string `aws/dynamodb:"tenants/acme"`
+		}
+
+		_, err := Load[config](ctx, loader)
+		require.NoError(t, err)
+
+		client.Tables["tenants"].Items["acme"]["Plan"] = &types.AttributeValueMemberS{Value: "startup"}
+		result, err := Load[config](ctx, loader)
+		require.NoError(t, err)
+		assert.Equal(t, "startup", result.Plan)
+	})
+
+	t.Run("Retries unprocessed keys", func(t *testing.T) {
+		client := setupMockClient()
+		client.UnprocessedOnce = true
+
+		result, err := Load[struct {
+			Plan string `aws/dynamodb:"tenants/acme"`
+		}](ctx, New(client))
+
+		require.NoError(t, err)
+		assert.Equal(t, "enterprise", result.Plan)
+		assert.Equal(t, 2, client.BatchGetCalls)
+	})
+
+	t.Run("Fails only the keys left unprocessed", func(t *testing.T) {
+		client := setupMockClient()
+		client.Throttled = map[string]bool{"tenants/globex": true}
+
+		var plan, throttled string
+		errs := New(client).(BatchLoader).LoadBatch(ctx, []FieldRequest{
+			{Field: reflect.StructField{Name: "Plan"}, Value: reflect.ValueOf(&plan).Elem(), Tag: "tenants/acme"},
+			{Field: reflect.StructField{Name: "Plan"}, Value: reflect.ValueOf(&throttled).Elem(), Tag: "tenants/globex"},
+		})
+
+		require.NoError(t, errs[0])
+		assert.Equal(t, "enterprise", plan)
+		assert.ErrorContains(t, errs[1], "keys remained unprocessed")
+	})
+
+	t.Run("Reads key values containing slashes", func(t *testing.T) {
+		client := setupMockClient()
+		client.Tables["tenants"].Items["acme/eu"] = map[string]types.AttributeValue{
+			"TenantID": &types.AttributeValueMemberS{Value: "acme/eu"},
+			"Plan":     &types.AttributeValueMemberS{Value: "regional"},
+		}
+		t.Setenv("TENANT", "acme/eu")
+
+		result, err := Load[struct {
+			Tenant string `env:"TENANT"`
+			Plan   string `aws/dynamodb:"tenants/@Tenant"`
+		}](ctx, env.New(), New(client))
+
+		require.NoError(t, err)
+		assert.Equal(t, "regional", result.Plan)
+	})
+
+	t.Run("Handles optional items and attributes", func(t *testing.T) {
+		result, err := Load[struct {
+			Item      string `aws/dynamodb:"tenants/missing:Plan?"`
+			Attribute string `aws/dynamodb:"tenants/acme:Missing?"`
+		}](ctx, New(setupMockClient()))
+
+		require.NoError(t, err)
+		assert.Equal(t, "", result.Item)
+		assert.Equal(t, "", result.Attribute)
+	})
+
+	t.Run("Errors on missing required items and attributes", func(t *testing.T) {
+		_, err := Load[struct {
+			Value string `aws/dynamodb:"tenants/missing:Plan"`
+		}](ctx, New(setupMockClient()))
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "tenants/missing")
+
+		_, err = Load[struct {
+			Value string `aws/dynamodb:"tenants/acme:Missing"`
+		}](ctx, New(setupMockClient()))
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "Missing")
+	})
+
+	t.Run("Errors on keys that do not match the table schema", func(t *testing.T) {
+		_, err := Load[struct {
+			Value string `aws/dynamodb:"settings/7:TTL"`
+		}](ctx, New(setupMockClient()))
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "sort key")
+	})
+
+	t.Run("Handles references to other fields", func(t *testing.T) {
+		t.Setenv("TENANT_ID", "acme")
+
+		result, err := Load[struct {
+			TenantID string `env:"TENANT_ID"`
+			MaxUsers int    `aws/dynamodb:"tenants/||@TenantID||:MaxUsers"`
+		}](ctx, env.New(), New(setupMockClient()))
+
+		require.NoError(t, err)
+		assert.Equal(t, "acme", result.TenantID)
+		assert.Equal(t, 25, result.MaxUsers)
+	})
+}
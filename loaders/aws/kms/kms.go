@@ -0,0 +1,115 @@
+package kms
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/Gardego5/gocfg"
+	"github.com/Gardego5/gocfg/utils"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+)
+
+type decrypter interface {
+	Decrypt(
+		ctx context.Context,
+		params *kms.DecryptInput,
+		optFns ...func(*kms.Options),
+	) (*kms.DecryptOutput, error)
+}
+
+// KMSLoader decrypts AWS KMS ciphertext obtained from the tag, usually by
+// referencing another field that holds the encrypted value.
+func New(client ...decrypter) gocfg.Loader {
+	var c decrypter
+	switch len(client) {
+	case 0:
+		awsConfig, err := config.LoadDefaultConfig(context.Background())
+		if err != nil {
+			panic(err)
+		}
+
+		c = kms.NewFromConfig(awsConfig)
+
+	case 1:
+		c = client[0]
+
+	default:
+		panic("too many arguments")
+	}
+
+	return &loader{client: c}
+}
+
+type loader struct{ client decrypter }
+
+func (*loader) GocfgLoaderName() string { return "aws/kms" }
+func (*loader) GocfgEscapedTags() bool  { return true }
+
+// Load implements the Loader interface for AWS KMS
+// Tag formats supported:
+// - "@Field" - Decrypt the base64 ciphertext held by another field
+// - "@Field||,key=value,..." - Decrypt with the given encryption context
+// - "@Field?" - Optional, skipped when the ciphertext is empty. "@Field||?"
+// is equivalent.
+// - "AQICAHh..." - Decrypt a literal base64 ciphertext
+func (l *loader) Load(
+	ctx context.Context,
+	field reflect.StructField, value reflect.Value,
+	resolvedTag string,
+) error {
+	// Handle special case - fully resolved reference or concatenation
+	if strings.HasPrefix(resolvedTag, "@") || strings.Contains(resolvedTag, "||") {
+		// At this point the tag should be resolved already
+		return fmt.Errorf("unexpected unresolved tag: %s", resolvedTag)
+	}
+
+	tag := strings.TrimSpace(resolvedTag)
+
+	// Check if the value is optional
+	tag, isOptional := utils.CutTagSuffix(tag, "?")
+
+	// Split the ciphertext from the encryption context
+	options := utils.SplitTag(tag, ",")
+	ciphertext := utils.UnescapeTag(strings.TrimSpace(options[0]))
+
+	var encryptionContext map[string]string
+	for _, option := range options[1:] {
+		key, val, ok := utils.CutTag(option, "=")
+		if !ok || strings.TrimSpace(key) == "" {
+			return utils.InvalidTagError("invalid encryption context %q: expected key=value", option)
+		}
+
+		if encryptionContext == nil {
+			encryptionContext = make(map[string]string)
+		}
+		encryptionContext[utils.UnescapeTag(strings.TrimSpace(key))] = utils.UnescapeTag(strings.TrimSpace(val))
+	}
+
+	if ciphertext == "" {
+		return utils.NotFoundError(isOptional, "no ciphertext for field %s", field.Name)
+	}
+
+	blob, err := base64.StdEncoding.DecodeString(ciphertext)
+	if err != nil {
+		return fmt.Errorf("ciphertext for field %s is not valid base64: %w", field.Name, err)
+	}
+
+	result, err := l.client.Decrypt(ctx, &kms.DecryptInput{
+		CiphertextBlob:    blob,
+		EncryptionContext: encryptionContext,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to decrypt field %s: %w", field.Name, err)
+	}
+
+	if _, ok := value.Addr().Interface().(*[]byte); ok {
+		value.SetBytes(result.Plaintext)
+		return nil
+	}
+
+	return utils.SetFieldValue(value, string(result.Plaintext))
+}
@@ -0,0 +1,128 @@
+package kms_test
+
+import (
+	"context"
+	"encoding/base64"
+	"maps"
+	"strings"
+	"testing"
+
+	. "github.com/Gardego5/gocfg"
+	. "github.com/Gardego5/gocfg/loaders/aws/kms"
+	"github.com/Gardego5/gocfg/loaders/env"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/aws/aws-sdk-go-v2/service/kms/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// MockKMSClient implements a mock for AWS KMS client. Ciphertexts are the
+// plaintext prefixed with "encrypted:", and decrypting them requires the
+// configured encryption context.
+type MockKMSClient struct {
+	EncryptionContext map[string]string
+}
+
+// Decrypt implements the KMS Decrypt operation
+func (m *MockKMSClient) Decrypt(
+	ctx context.Context,
+	params *kms.DecryptInput,
+	optFns ...func(*kms.Options),
+) (*kms.DecryptOutput, error) {
+	plaintext, ok := strings.CutPrefix(string(params.CiphertextBlob), "encrypted:")
+	if !ok || !maps.Equal(params.EncryptionContext, m.EncryptionContext) {
+		return nil, &types.InvalidCiphertextException{Message: aws.String("invalid ciphertext")}
+	}
+
+	return &kms.DecryptOutput{Plaintext: []byte(plaintext)}, nil
+}
+
+func encrypt(plaintext string) string {
+	return base64.StdEncoding.EncodeToString([]byte("encrypted:" + plaintext))
+}
+
+func TestKMSLoader(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Decrypts ciphertext referenced from another field", func(t *testing.T) {
+		t.Setenv("ENCRYPTED_DB_PASS", encrypt("hunter2"))
+
+		result, err := Load[struct {
+			EncryptedDBPass string `env:"ENCRYPTED_DB_PASS"`
+			DBPass          string `aws/kms:"@EncryptedDBPass"`
+		}](ctx, env.New(), New(&MockKMSClient{}))
+
+		require.NoError(t, err)
+		assert.Equal(t, "hunter2", result.DBPass)
+	})
+
+	t.Run("Decrypts into typed fields", func(t *testing.T) {
+		t.Setenv("ENCRYPTED_PORT", encrypt("5432"))
+
+		result, err := Load[struct {
+			EncryptedPort string `env:"ENCRYPTED_PORT"`
+			Port          int    `aws/kms:"@EncryptedPort"`
+			Raw           []byte `aws/kms:"@EncryptedPort"`
+		}](ctx, env.New(), New(&MockKMSClient{}))
+
+		require.NoError(t, err)
+		assert.Equal(t, 5432, result.Port)
+		assert.Equal(t, []byte("5432"), result.Raw)
+	})
+
+	t.Run("Passes encryption context from tag options", func(t *testing.T) {
+		t.Setenv("ENCRYPTED_DB_PASS", encrypt("hunter2"))
+		client := &MockKMSClient{EncryptionContext: map[string]string{
+			"purpose": "db",
+			"app":     "billing",
+		}}
+
+		result, err := Load[struct {
+			EncryptedDBPass string `env:"ENCRYPTED_DB_PASS"`
+			DBPass          string `aws/kms:"@EncryptedDBPass||,purpose=db,app=billing"`
+		}](ctx, env.New(), New(client))
+
+		require.NoError(t, err)
+		assert.Equal(t, "hunter2", result.DBPass)
+
+		_, err = Load[struct {
+			EncryptedDBPass string `env:"ENCRYPTED_DB_PASS"`
+			DBPass          string `aws/kms:"@EncryptedDBPass"`
+		}](ctx, env.New(), New(client))
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "DBPass")
+	})
+
+	t.Run("Handles optional ciphertext", func(t *testing.T) {
+		result, err := Load[struct {
+			EncryptedDBPass string `env:"ENCRYPTED_DB_PASS?"`
+			DBPass          string `aws/kms:"@EncryptedDBPass?"`
+			Concatenated    string `aws/kms:"@EncryptedDBPass||?"`
+		}](ctx, env.New(), New(&MockKMSClient{}))
+
+		require.NoError(t, err)
+		assert.Equal(t, "", result.DBPass)
+		assert.Equal(t, "", result.Concatenated)
+	})
+
+	t.Run("Errors on missing required ciphertext", func(t *testing.T) {
+		_, err := Load[struct {
+			EncryptedDBPass string `env:"ENCRYPTED_DB_PASS?"`
+			DBPass          string `aws/kms:"@EncryptedDBPass"`
+		}](ctx, env.New(), New(&MockKMSClient{}))
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "no ciphertext")
+	})
+
+	t.Run("Errors on invalid base64", func(t *testing.T) {
+		_, err := Load[struct {
+			DBPass string `aws/kms:"not base64!"`
+		}](ctx, New(&MockKMSClient{}))
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "base64")
+	})
+}
@@ -0,0 +1,202 @@
+package s3
+
+import (
+	"context"
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/Gardego5/gocfg"
+	"github.com/Gardego5/gocfg/utils"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"gopkg.in/yaml.v3"
+)
+
+type getObjecter interface {
+	GetObject(
+		ctx context.Context,
+		params *s3.GetObjectInput,
+		optFns ...func(*s3.Options),
+	) (*s3.GetObjectOutput, error)
+}
+
+// S3Loader loads configuration from objects stored in AWS S3
+func New(client ...getObjecter) gocfg.Loader {
+	var c getObjecter
+	switch len(client) {
+	case 0:
+		awsConfig, err := config.LoadDefaultConfig(context.Background())
+		if err != nil {
+			panic(err)
+		}
+
+		c = s3.NewFromConfig(awsConfig)
+
+	case 1:
+		c = client[0]
+
+	default:
+		panic("too many arguments")
+	}
+
+	return &loader{client: c, objects: make(map[string]object)}
+}
+
+// object is a previously downloaded object, kept so that later loads can ask
+// S3 whether it changed instead of downloading it again.
+type object struct {
+	etag string
+	body []byte
+}
+
+type loader struct {
+	client getObjecter
+
+	mu      sync.Mutex
+	objects map[string]object
+}
+
+func (*loader) GocfgLoaderName() string { return "aws/s3" }
+func (*loader) GocfgEscapedTags() bool  { return true }
+
+// Load implements the Loader interface for AWS S3
+// Tag formats supported:
+// - "s3://bucket/key" - Load the object body into the field
+// - "s3://bucket/key?" - Optional object
+// - "s3://||@Bucket||/||@Key" - Build the bucket or key from other fields
+//
+// []byte and string fields receive the raw body. Structs, maps and slices are
+// decoded as YAML when the key ends in .yaml or .yml and as JSON otherwise.
+// Any other field type is set from the body as text.
+func (l *loader) Load(
+	ctx context.Context,
+	field reflect.StructField, value reflect.Value,
+	resolvedTag string,
+) error {
+	// Handle special case - fully resolved reference or concatenation
+	if strings.HasPrefix(resolvedTag, "@") || strings.Contains(resolvedTag, "||") {
+		// At this point the tag should be resolved already
+		return fmt.Errorf("unexpected unresolved tag: %s", resolvedTag)
+	}
+
+	tag := strings.TrimSpace(resolvedTag)
+
+	// Check if object is optional
+	tag, isOptional := utils.CutTagSuffix(tag, "?")
+
+	bucket, key, err := parseURI(tag)
+	if err != nil {
+		return err
+	}
+	uri := "s3://" + bucket + "/" + key
+
+	body, err := l.getObject(ctx, bucket, key)
+	if err != nil {
+		var noSuchKey *types.NoSuchKey
+		var notFound *types.NotFound
+		if errors.As(err, &noSuchKey) || errors.As(err, &notFound) {
+			return utils.NotFoundError(isOptional, "object %s not found", uri)
+		}
+		return fmt.Errorf("failed to retrieve object %s: %w", uri, err)
+	}
+
+	return setFieldBytes(value, body, key)
+}
+
+// parseURI splits an s3://bucket/key URI into its bucket and key
+func parseURI(uri string) (bucket, key string, err error) {
+	rest, ok := strings.CutPrefix(uri, "s3://")
+	if !ok {
+		return "", "", utils.InvalidTagError("invalid S3 URI %q: expected s3://bucket/key", uri)
+	}
+
+	bucket, key, _ = utils.CutTag(rest, "/")
+	if bucket == "" || key == "" {
+		return "", "", utils.InvalidTagError("invalid S3 URI %q: expected s3://bucket/key", uri)
+	}
+
+	return utils.UnescapeTag(bucket), utils.UnescapeTag(key), nil
+}
+
+// getObject downloads an object, sending the ETag of any earlier download so
+// that an unchanged object is served from memory instead.
+func (l *loader) getObject(ctx context.Context, bucket, key string) ([]byte, error) {
+	uri := "s3://" + bucket + "/" + key
+
+	l.mu.Lock()
+	cached, isCached := l.objects[uri]
+	l.mu.Unlock()
+
+	input := &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	}
+	if isCached {
+		input.IfNoneMatch = aws.String(cached.etag)
+	}
+
+	result, err := l.client.GetObject(ctx, input)
+	if err != nil {
+		var statusErr interface{ HTTPStatusCode() int }
+		if isCached && errors.As(err, &statusErr) && statusErr.HTTPStatusCode() == http.StatusNotModified {
+			return cached.body, nil
+		}
+		return nil, err
+	}
+	defer result.Body.Close()
+
+	body, err := io.ReadAll(result.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read object %s: %w", uri, err)
+	}
+
+	if etag := aws.ToString(result.ETag); etag != "" {
+		l.mu.Lock()
+		l.objects[uri] = object{etag: etag, body: body}
+		l.mu.Unlock()
+	}
+
+	return body, nil
+}
+
+// setFieldBytes sets the field from an object body based on the field's type
+func setFieldBytes(fieldValue reflect.Value, body []byte, key string) error {
+	switch fieldValue.Addr().Interface().(type) {
+	case *[]byte:
+		fieldValue.SetBytes(body)
+		return nil
+
+	case encoding.TextUnmarshaler, encoding.BinaryUnmarshaler, json.Unmarshaler:
+		return utils.SetFieldValue(fieldValue, string(body))
+	}
+
+	switch fieldValue.Kind() {
+	case reflect.Struct, reflect.Map, reflect.Slice, reflect.Array, reflect.Pointer:
+		target := fieldValue.Addr().Interface()
+
+		switch strings.ToLower(path.Ext(key)) {
+		case ".yaml", ".yml":
+			if err := yaml.Unmarshal(body, target); err != nil {
+				return fmt.Errorf("failed to decode YAML object %s: %w", key, err)
+			}
+		default:
+			if err := json.Unmarshal(body, target); err != nil {
+				return fmt.Errorf("failed to decode JSON object %s: %w", key, err)
+			}
+		}
+		return nil
+
+	default:
+		return utils.SetFieldValue(fieldValue, string(body))
+	}
+}
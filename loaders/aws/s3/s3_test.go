@@ -0,0 +1,191 @@
+package s3_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"testing"
+
+	. "github.com/Gardego5/gocfg"
+	. "github.com/Gardego5/gocfg/loaders/aws/s3"
+	"github.com/Gardego5/gocfg/loaders/env"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// MockS3Client implements a mock for AWS S3 client
+type MockS3Client struct {
+	Objects   map[string]string // Map of "bucket/key" to object body
+	ETags     map[string]string // Map of "bucket/key" to object ETag
+	Downloads int               // Number of full object downloads served
+}
+
+// GetObject implements the S3 GetObject operation
+func (m *MockS3Client) GetObject(
+	ctx context.Context,
+	params *s3.GetObjectInput,
+	optFns ...func(*s3.Options),
+) (*s3.GetObjectOutput, error) {
+	name := aws.ToString(params.Bucket) + "/" + aws.ToString(params.Key)
+
+	body, exists := m.Objects[name]
+	if !exists {
+		return nil, &types.NoSuchKey{Message: aws.String("Object " + name + " not found")}
+	}
+
+	etag := m.ETags[name]
+	if etag != "" && aws.ToString(params.IfNoneMatch) == etag {
+		return nil, &awshttp.ResponseError{ResponseError: &smithyhttp.ResponseError{
+			Response: &smithyhttp.Response{Response: &http.Response{StatusCode: http.StatusNotModified}},
+		}}
+	}
+
+	m.Downloads++
+	return &s3.GetObjectOutput{
+		Body: io.NopCloser(bytes.NewBufferString(body)),
+		ETag: aws.String(etag),
+	}, nil
+}
+
+func setupMockClient() *MockS3Client {
+	// Initialize mock client with predefined objects
+	return &MockS3Client{
+		Objects: map[string]string{
+			"certs/ca.pem":          "-----BEGIN CERTIFICATE-----",
+			"configs/app.json":      `{"name": "app", "replicas": 3}`,
+			"configs/app.yaml":      "name: app\nreplicas: 3\n",
+			"configs/timeout":       "42",
+			"testapp/settings.json": `{"name": "testapp", "replicas": 1}`,
+		},
+		ETags: map[string]string{
+			"configs/app.json": `"etag-app-json"`,
+		},
+	}
+}
+
+type appConfig struct {
+	Name     string `json:"name" yaml:"name"`
+	Replicas int    `json:"replicas" yaml:"replicas"`
+}
+
+func TestS3Loader(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Loads object into byte slice and string", func(t *testing.T) {
+		loader := New(setupMockClient())
+
+		result, err := Load[struct {
+			Bytes  []byte `aws/s3:"s3://certs/ca.pem"`
+			String string `aws/s3:"s3://certs/ca.pem"`
+		}](ctx, loader)
+
+		require.NoError(t, err)
+		assert.Equal(t, []byte("-----BEGIN CERTIFICATE-----"), result.Bytes)
+		assert.Equal(t, "-----BEGIN CERTIFICATE-----", result.String)
+	})
+
+	t.Run("Decodes JSON and YAML objects into structs", func(t *testing.T) {
+		loader := New(setupMockClient())
+
+		result, err := Load[struct {
+			FromJSON appConfig `aws/s3:"s3://configs/app.json"`
+			FromYAML appConfig `aws/s3:"s3://configs/app.yaml"`
+		}](ctx, loader)
+
+		require.NoError(t, err)
+		assert.Equal(t, appConfig{Name: "app", Replicas: 3}, result.FromJSON)
+		assert.Equal(t, appConfig{Name: "app", Replicas: 3}, result.FromYAML)
+	})
+
+	t.Run("Sets scalar fields from the object text", func(t *testing.T) {
+		loader := New(setupMockClient())
+
+		result, err := Load[struct {
+			Timeout int `aws/s3:"s3://configs/timeout"`
+		}](ctx, loader)
+
+		require.NoError(t, err)
+		assert.Equal(t, 42, result.Timeout)
+	})
+
+	t.Run("Handles optional objects", func(t *testing.T) {
+		loader := New(setupMockClient())
+
+		result, err := Load[struct {
+			Value []byte `aws/s3:"s3://certs/missing.pem?"`
+		}](ctx, loader)
+
+		require.NoError(t, err)
+		assert.Nil(t, result.Value)
+	})
+
+	t.Run("Errors on missing required objects", func(t *testing.T) {
+		loader := New(setupMockClient())
+
+		_, err := Load[struct {
+			Value []byte `aws/s3:"s3://certs/missing.pem"`
+		}](ctx, loader)
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "s3://certs/missing.pem")
+	})
+
+	t.Run("Errors on invalid URIs", func(t *testing.T) {
+		loader := New(setupMockClient())
+
+		_, err := Load[struct {
+			Value []byte `aws/s3:"certs/ca.pem"`
+		}](ctx, loader)
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid S3 URI")
+	})
+
+	t.Run("Handles references to other fields", func(t *testing.T) {
+		loader := New(setupMockClient())
+
+		t.Setenv("APP_NAME", "testapp")
+
+		result, err := Load[struct {
+			AppName  string    `env:"APP_NAME"`
+			Settings appConfig `aws/s3:"s3://||@AppName||/settings.json"`
+		}](ctx, env.New(), loader)
+
+		require.NoError(t, err)
+		assert.Equal(t, appConfig{Name: "testapp", Replicas: 1}, result.Settings)
+	})
+
+	t.Run("Skips downloading unchanged objects", func(t *testing.T) {
+		client := setupMockClient()
+		loader := New(client)
+
+		type Config struct {
+			App appConfig `aws/s3:"s3://configs/app.json"`
+		}
+
+		first, err := Load[Config](ctx, loader)
+		require.NoError(t, err)
+
+		second, err := Load[Config](ctx, loader)
+		require.NoError(t, err)
+
+		assert.Equal(t, 1, client.Downloads)
+		assert.Equal(t, first, second)
+
+		// Changing the object changes its ETag, so it is downloaded again
+		client.Objects["configs/app.json"] = `{"name": "app", "replicas": 5}`
+		client.ETags["configs/app.json"] = `"etag-app-json-2"`
+
+		third, err := Load[Config](ctx, loader)
+		require.NoError(t, err)
+
+		assert.Equal(t, 2, client.Downloads)
+		assert.Equal(t, 5, third.App.Replicas)
+	})
+}
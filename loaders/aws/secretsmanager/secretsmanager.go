@@ -3,6 +3,7 @@ package secretsmanager
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"reflect"
 	"strings"
@@ -12,6 +13,7 @@ import (
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager/types"
 )
 
 type getSecretValuer interface {
@@ -44,9 +46,23 @@ func New(client ...getSecretValuer) gocfg.Loader {
 	return &loader{client: c}
 }
 
+// batchGetSecretValuer is implemented by clients that can fetch several
+// secrets at once, like the Secrets Manager client
+type batchGetSecretValuer interface {
+	BatchGetSecretValue(
+		ctx context.Context,
+		params *secretsmanager.BatchGetSecretValueInput,
+		optFns ...func(*secretsmanager.Options),
+	) (*secretsmanager.BatchGetSecretValueOutput, error)
+}
+
+// maxBatchSecrets is the most secrets BatchGetSecretValue returns per call
+const maxBatchSecrets = 20
+
 type loader struct{ client getSecretValuer }
 
 func (s *loader) GocfgLoaderName() string { return "aws/secretsmanager" }
+func (*loader) GocfgEscapedTags() bool    { return true }
 
 // Load implements the Loader interface for AWS Secrets Manager
 // Tag formats supported:
@@ -61,99 +77,198 @@ func (s *loader) Load(
 	field reflect.StructField, value reflect.Value,
 	resolvedTag string,
 ) error {
+	ref, err := parseTag(field, resolvedTag)
+	if err != nil {
+		return err
+	}
+
+	secretValue, err := s.getSecretValue(ctx, ref.name)
+	return ref.set(field, value, secretValue, err)
+}
+
+// LoadBatch implements gocfg.BatchLoader. When the client also supports
+// BatchGetSecretValue, the secrets of all fields are fetched with it, up to
+// maxBatchSecrets per call, and each secret is fetched once however many
+// fields read it. Secrets the batch can't return are fetched one by one.
+func (s *loader) LoadBatch(ctx context.Context, requests []gocfg.FieldRequest) []error {
+	errs := make([]error, len(requests))
+	refs := make([]secretRef, len(requests))
+
+	var names []string
+	seen := make(map[string]bool)
+	for i, request := range requests {
+		if refs[i], errs[i] = parseTag(request.Field, request.Tag); errs[i] == nil && !seen[refs[i].name] {
+			seen[refs[i].name] = true
+			names = append(names, refs[i].name)
+		}
+	}
+
+	secrets := s.batchGetSecretValues(ctx, names)
+
+	for i, request := range requests {
+		if errs[i] != nil {
+			continue
+		}
+
+		fetched, exists := secrets[refs[i].name]
+		if !exists {
+			fetched.value, fetched.err = s.getSecretValue(ctx, refs[i].name)
+			secrets[refs[i].name] = fetched
+		}
+
+		errs[i] = refs[i].set(request.Field, request.Value, fetched.value, fetched.err)
+	}
+
+	return errs
+}
+
+// secretRef is a parsed tag
+type secretRef struct {
+	name     string
+	key      string
+	optional bool
+}
+
+// parseTag parses the tag of a field
+func parseTag(field reflect.StructField, resolvedTag string) (ref secretRef, err error) {
 	// Handle special case - fully resolved reference or concatenation
 	if strings.HasPrefix(resolvedTag, "@") || strings.Contains(resolvedTag, "||") {
 		// At this point the tag should be resolved already
-		return fmt.Errorf("unexpected unresolved tag: %s", resolvedTag)
+		return ref, fmt.Errorf("unexpected unresolved tag: %s", resolvedTag)
 	}
 
-	// Parse the tag
-	var secretName string
-	var jsonKey string
-	var isOptional bool
-
 	// Check if secret is optional
-	if strings.HasSuffix(resolvedTag, "?") {
-		isOptional = true
-		resolvedTag = strings.TrimSuffix(resolvedTag, "?")
-	}
+	resolvedTag, ref.optional = utils.CutTagSuffix(strings.TrimSpace(resolvedTag), "?")
 
 	// Check for JSON key specification
-	if idx := strings.Index(resolvedTag, ":"); idx >= 0 {
-		secretName = strings.TrimSpace(resolvedTag[:idx])
-		jsonKey = strings.TrimSpace(resolvedTag[idx+1:])
+	if name, key, hasKey := utils.CutTag(resolvedTag, ":"); hasKey {
+		ref.name = utils.UnescapeTag(strings.TrimSpace(name))
+		ref.key = utils.UnescapeTag(strings.TrimSpace(key))
 	} else {
-		secretName = strings.TrimSpace(resolvedTag)
+		ref.name = utils.UnescapeTag(strings.TrimSpace(resolvedTag))
 		// If no key specified, use the field name as the key
-		jsonKey = field.Name
+		ref.key = field.Name
 	}
 
-	// Get the secret value from AWS Secrets Manager
-	input := &secretsmanager.GetSecretValueInput{
-		SecretId: aws.String(secretName),
-	}
+	return ref, nil
+}
 
-	result, err := s.client.GetSecretValue(ctx, input)
+// set sets a field from the value of the secret it references, or reports
+// the error fetching the secret
+func (ref secretRef) set(field reflect.StructField, value reflect.Value, secretValue string, err error) error {
 	if err != nil {
 		// Check if the error is because the secret doesn't exist
-		if isOptional {
-			return nil // Skip this field if it's optional
+		var notFound *types.ResourceNotFoundException
+		if errors.As(err, &notFound) {
+			return utils.NotFoundError(ref.optional, "secret %s not found", ref.name)
 		}
-		return fmt.Errorf("failed to retrieve secret %s: %w", secretName, err)
-	}
-
-	var secretValue string
-	if result.SecretString != nil {
-		secretValue = *result.SecretString
-	} else if result.SecretBinary != nil {
-		// Handle binary secrets if needed
-		return fmt.Errorf("binary secrets not supported for field %s", field.Name)
-	} else {
-		return fmt.Errorf("empty secret returned for %s", secretName)
+		if ref.optional {
+			return fmt.Errorf("%w: failed to retrieve secret %s: %v", utils.ErrNotFound, ref.name, err)
+		}
+		return fmt.Errorf("failed to retrieve secret %s: %w", ref.name, err)
 	}
 
 	// Try to parse the secret as JSON
 	var secretMap map[string]interface{}
 	if err := json.Unmarshal([]byte(secretValue), &secretMap); err != nil {
 		// Not a JSON object, use the whole string
-		if jsonKey != "" && jsonKey != field.Name {
-			return fmt.Errorf("cannot extract key %s from non-JSON secret %s", jsonKey, secretName)
+		if ref.key != "" && ref.key != field.Name {
+			return fmt.Errorf("cannot extract key %s from non-JSON secret %s", ref.key, ref.name)
 		}
 		return utils.SetFieldValue(value, secretValue)
 	}
 
 	// Extract the specific key from the JSON
-	if jsonValue, exists := secretMap[jsonKey]; exists {
-		// Convert the value to string based on its type
-		var stringValue string
-		switch v := jsonValue.(type) {
-		case string:
-			stringValue = v
-		case float64:
-			if v == float64(int(v)) {
-				stringValue = fmt.Sprintf("%.0f", v)
-			} else {
-				stringValue = fmt.Sprintf("%g", v)
-			}
-		case bool:
-			stringValue = fmt.Sprintf("%t", v)
-		case nil:
-			stringValue = ""
-		default:
-			// For complex types, re-encode as JSON
-			bytes, err := json.Marshal(v)
+	if jsonValue, exists := secretMap[ref.key]; exists {
+		return utils.SetFieldJSONValue(value, jsonValue)
+	}
+
+	return utils.NotFoundError(ref.optional, "key %s not found in secret %s", ref.key, ref.name)
+}
+
+// getSecretValue fetches the string value of a secret
+func (s *loader) getSecretValue(ctx context.Context, name string) (string, error) {
+	result, err := s.client.GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{
+		SecretId: aws.String(name),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return secretString(name, result.SecretString, result.SecretBinary)
+}
+
+// fetchedSecret is the value of a secret, or the error fetching it
+type fetchedSecret struct {
+	value string
+	err   error
+}
+
+// batchGetSecretValues fetches secrets with BatchGetSecretValue, if the client
+// supports it. Secrets missing from the result, because the batch failed or
+// returned an error other than not found for them, are left to be fetched
+// individually.
+func (s *loader) batchGetSecretValues(ctx context.Context, names []string) map[string]fetchedSecret {
+	secrets := make(map[string]fetchedSecret)
+
+	client, ok := s.client.(batchGetSecretValuer)
+	if !ok {
+		return secrets
+	}
+
+	for start := 0; start < len(names); start += maxBatchSecrets {
+		chunk := names[start:min(start+maxBatchSecrets, len(names))]
+
+		requested := make(map[string]bool, len(chunk))
+		for _, name := range chunk {
+			requested[name] = true
+		}
+
+		input := &secretsmanager.BatchGetSecretValueInput{SecretIdList: chunk}
+		for {
+			result, err := client.BatchGetSecretValue(ctx, input)
 			if err != nil {
-				return fmt.Errorf("failed to marshal complex secret value: %w", err)
+				break
 			}
-			stringValue = string(bytes)
-		}
 
-		return utils.SetFieldValue(value, stringValue)
-	}
+			for _, entry := range result.SecretValues {
+				// Secrets may be requested by name or ARN
+				name := aws.ToString(entry.Name)
+				if !requested[name] {
+					name = aws.ToString(entry.ARN)
+				}
+
+				var fetched fetchedSecret
+				fetched.value, fetched.err = secretString(name, entry.SecretString, entry.SecretBinary)
+				secrets[name] = fetched
+			}
 
-	if isOptional {
-		return nil
+			for _, apiErr := range result.Errors {
+				if aws.ToString(apiErr.ErrorCode) == "ResourceNotFoundException" {
+					secrets[aws.ToString(apiErr.SecretId)] = fetchedSecret{
+						err: &types.ResourceNotFoundException{Message: apiErr.Message},
+					}
+				}
+			}
+
+			if result.NextToken == nil {
+				break
+			}
+			input = &secretsmanager.BatchGetSecretValueInput{SecretIdList: chunk, NextToken: result.NextToken}
+		}
 	}
 
-	return fmt.Errorf("key %s not found in secret %s", jsonKey, secretName)
+	return secrets
+}
+
+// secretString returns the string value of a fetched secret
+func secretString(name string, value *string, binary []byte) (string, error) {
+	switch {
+	case value != nil:
+		return *value, nil
+	case binary != nil:
+		return "", fmt.Errorf("binary secret %s not supported", name)
+	default:
+		return "", fmt.Errorf("empty secret returned for %s", name)
+	}
 }
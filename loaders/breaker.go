@@ -0,0 +1,111 @@
+package loaders
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/Gardego5/gocfg"
+)
+
+// ErrCircuitOpen is returned by loaders wrapped with WithCircuitBreaker while
+// they fail fast
+var ErrCircuitOpen = errors.New("circuit open")
+
+type breakerLoader struct {
+	wrapper
+	failures int
+	cooldown time.Duration
+	clock    Clock
+
+	mu          sync.Mutex
+	consecutive int       // Failed loads in a row
+	openedAt    time.Time // When the circuit last opened, if open
+	open        bool
+	probing     bool // A load is testing whether the loader recovered
+}
+
+// WithCircuitBreaker stops calling a loader that failed failures times in a
+// row, failing its loads with ErrCircuitOpen instead. After cooldown, one
+// load is let through: the circuit closes if it succeeds and stays open for
+// another cooldown if it fails. Loads reporting that there is no value, like
+// utils.ErrNotFound, count as successes.
+func WithCircuitBreaker(loader gocfg.Loader, failures int, cooldown time.Duration, opts ...Option) gocfg.Loader {
+	b := &breakerLoader{
+		wrapper: wrapper{loader}, failures: failures, cooldown: cooldown,
+		clock: newOptions(opts).clock,
+	}
+	return forward(b, loader, b.loadBatch)
+}
+
+func (b *breakerLoader) Load(
+	ctx context.Context,
+	field reflect.StructField, value reflect.Value,
+	resolvedTag string,
+) error {
+	if err := b.allow(); err != nil {
+		return err
+	}
+
+	err := b.inner.Load(ctx, field, value, resolvedTag)
+	b.record(isFailure(ctx, err))
+	return err
+}
+
+// loadBatch loads a batch as a single call, failing if any field failed
+func (b *breakerLoader) loadBatch(ctx context.Context, requests []gocfg.FieldRequest) []error {
+	if err := b.allow(); err != nil {
+		errs := make([]error, len(requests))
+		for i := range errs {
+			errs[i] = err
+		}
+		return errs
+	}
+
+	errs := b.inner.(gocfg.BatchLoader).LoadBatch(ctx, requests)
+
+	failed := false
+	for _, err := range errs {
+		failed = failed || isFailure(ctx, err)
+	}
+	b.record(failed)
+
+	return errs
+}
+
+// allow returns ErrCircuitOpen if a load must fail fast
+func (b *breakerLoader) allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.open {
+		return nil
+	}
+
+	if b.probing || b.clock.Now().Before(b.openedAt.Add(b.cooldown)) {
+		return fmt.Errorf("%w: %s failed %d times in a row", ErrCircuitOpen, b.GocfgLoaderName(), b.consecutive)
+	}
+
+	b.probing = true
+	return nil
+}
+
+// record counts a load that was allowed
+func (b *breakerLoader) record(failed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+	if !failed {
+		b.consecutive, b.open = 0, false
+		return
+	}
+
+	b.consecutive++
+	if b.open || b.consecutive >= b.failures {
+		b.open, b.openedAt = true, b.clock.Now()
+	}
+}
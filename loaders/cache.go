@@ -0,0 +1,202 @@
+package loaders
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Gardego5/gocfg"
+)
+
+// CacheEntry is a value loaded by a loader, encoded as JSON
+type CacheEntry struct {
+	Value    json.RawMessage `json:"value"`
+	LoadedAt time.Time       `json:"loadedAt"`
+}
+
+// CacheStore persists the values cached by WithCache, so they outlive the
+// process
+type CacheStore interface {
+	// Get returns the entry stored for key, or false if there is none
+	Get(key string) (CacheEntry, bool, error)
+	// Put stores the entries by key, replacing any others for the same keys
+	Put(entries map[string]CacheEntry) error
+}
+
+type cacheLoader struct {
+	wrapper
+	store    CacheStore
+	ttl      time.Duration
+	maxStale time.Duration
+	clock    Clock
+
+	mu      sync.Mutex
+	entries map[string]CacheEntry
+	pending map[string]CacheEntry // Entries not yet written to store
+}
+
+// WithCache caches the values a loader loads. Values loaded less than ttl
+// ago are reused across Load calls without calling the loader. When the
+// loader fails, the last value it loaded is used instead if it was loaded
+// less than maxStale ago, from memory or else from store, which may be nil.
+// Values served from the cache are marked as cached in the Provenance.
+//
+// Loaded values are written to store together, at the end of each batch and
+// of each successful gocfg.Load. The store is a fallback, so failing to write
+// to it doesn't fail the load.
+func WithCache(loader gocfg.Loader, store CacheStore, ttl, maxStale time.Duration, opts ...Option) gocfg.Loader {
+	c := &cacheLoader{
+		wrapper: wrapper{loader}, store: store, ttl: ttl, maxStale: maxStale,
+		clock:   newOptions(opts).clock,
+		entries: make(map[string]CacheEntry),
+		pending: make(map[string]CacheEntry),
+	}
+	return forward(c, loader, c.loadBatch)
+}
+
+func (c *cacheLoader) Load(
+	ctx context.Context,
+	field reflect.StructField, value reflect.Value,
+	resolvedTag string,
+) error {
+	key := c.key(gocfg.FieldPath(ctx), field, value, resolvedTag)
+	if c.fresh(ctx, key, value) {
+		return nil
+	}
+
+	err := c.inner.Load(ctx, field, value, resolvedTag)
+	return c.result(ctx, key, value, err)
+}
+
+func (c *cacheLoader) loadBatch(ctx context.Context, requests []gocfg.FieldRequest) []error {
+	errs := make([]error, len(requests))
+	keys := make([]string, len(requests))
+
+	var missed []int // Indexes of the requests to load
+	for i, request := range requests {
+		keys[i] = c.key(request.Path, request.Field, request.Value, request.Tag)
+		if !c.fresh(request.Context(), keys[i], request.Value) {
+			missed = append(missed, i)
+		}
+	}
+	if len(missed) == 0 {
+		return errs
+	}
+
+	loading := make([]gocfg.FieldRequest, len(missed))
+	for j, i := range missed {
+		loading[j] = requests[i]
+	}
+
+	loadErrs := c.inner.(gocfg.BatchLoader).LoadBatch(ctx, loading)
+	if len(loadErrs) != len(loading) {
+		return loadErrs // Reported by the core
+	}
+
+	for j, i := range missed {
+		errs[i] = c.result(requests[i].Context(), keys[i], requests[i].Value, loadErrs[j])
+	}
+	c.flush()
+
+	return errs
+}
+
+// GocfgFinalize writes the values Load loaded to the store, then finalizes
+// the wrapped loader
+func (c *cacheLoader) GocfgFinalize(ctx context.Context) error {
+	c.flush()
+	return c.wrapper.GocfgFinalize(ctx)
+}
+
+// flush writes the entries loaded since the last flush to the store
+func (c *cacheLoader) flush() {
+	if c.store == nil {
+		return
+	}
+
+	c.mu.Lock()
+	pending := c.pending
+	c.pending = make(map[string]CacheEntry)
+	c.mu.Unlock()
+
+	if len(pending) > 0 {
+		_ = c.store.Put(pending)
+	}
+}
+
+// key identifies a field's value in the cache
+func (c *cacheLoader) key(path []reflect.StructField, field reflect.StructField, value reflect.Value, tag string) string {
+	names := make([]string, len(path))
+	for i, f := range path {
+		names[i] = f.Name
+	}
+	if len(names) == 0 {
+		names = append(names, field.Name)
+	}
+
+	return strings.Join([]string{c.GocfgLoaderName(), strings.Join(names, "."), value.Type().String(), tag}, "\x00")
+}
+
+// fresh sets value from the cache if it was loaded less than ttl ago
+func (c *cacheLoader) fresh(ctx context.Context, key string, value reflect.Value) bool {
+	c.mu.Lock()
+	entry, exists := c.entries[key]
+	c.mu.Unlock()
+
+	return exists && c.clock.Now().Sub(entry.LoadedAt) < c.ttl && c.set(ctx, entry, value) == nil
+}
+
+// result caches value if the loader loaded it, or falls back to the cache if
+// the loader failed
+func (c *cacheLoader) result(ctx context.Context, key string, value reflect.Value, err error) error {
+	if err == nil {
+		encoded, encodeErr := json.Marshal(value.Interface())
+		if encodeErr != nil {
+			return nil // Not cacheable
+		}
+
+		entry := CacheEntry{Value: encoded, LoadedAt: c.clock.Now()}
+		c.mu.Lock()
+		c.entries[key] = entry
+		if c.store != nil {
+			c.pending[key] = entry
+		}
+		c.mu.Unlock()
+		return nil
+	}
+
+	if !isFailure(ctx, err) {
+		return err
+	}
+
+	c.mu.Lock()
+	entry, exists := c.entries[key]
+	c.mu.Unlock()
+	if !exists && c.store != nil {
+		entry, exists, _ = c.store.Get(key)
+	}
+
+	if !exists || c.clock.Now().Sub(entry.LoadedAt) >= c.maxStale {
+		return err
+	}
+	if setErr := c.set(ctx, entry, value); setErr != nil {
+		return fmt.Errorf("%w (cached value unusable: %v)", err, setErr)
+	}
+	return nil
+}
+
+// set sets value from a cache entry, marking it as cached
+func (c *cacheLoader) set(ctx context.Context, entry CacheEntry, value reflect.Value) error {
+	decoded := reflect.New(value.Type())
+	if err := json.Unmarshal(entry.Value, decoded.Interface()); err != nil {
+		return err
+	}
+
+	value.Set(decoded.Elem())
+	gocfg.MarkCached(ctx, entry.LoadedAt)
+	return nil
+}
@@ -0,0 +1,236 @@
+package loaders_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Gardego5/gocfg"
+	"github.com/Gardego5/gocfg/loaders"
+	"github.com/Gardego5/gocfg/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var errUnavailable = errors.New("unavailable")
+
+// outageLoader loads its tags, or fails while down
+type outageLoader struct {
+	down  bool
+	calls int
+}
+
+func (*outageLoader) GocfgLoaderName() string { return "remote" }
+
+func (l *outageLoader) Load(_ context.Context, _ reflect.StructField, value reflect.Value, tag string) error {
+	l.calls++
+	if l.down {
+		return errUnavailable
+	}
+	if tag == "missing" {
+		return utils.NotFoundError(true, "missing")
+	}
+	return utils.SetFieldValue(value, tag)
+}
+
+type remoteConfig struct {
+	Password string        `remote:"hunter2"`
+	Timeout  time.Duration `remote:"5s"`
+	Port     int           `remote:"5432"`
+}
+
+func TestWithCache(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Reuses values for the TTL", func(t *testing.T) {
+		clock := &fakeClock{}
+		inner := &outageLoader{}
+		loader := loaders.WithCache(inner, nil, time.Minute, time.Hour, loaders.UsingClock(clock))
+
+		_, provenance, err := gocfg.LoadWithProvenance[remoteConfig](ctx, loader)
+		require.NoError(t, err)
+		assert.Equal(t, 3, inner.calls)
+		assert.False(t, provenance["Password"].Cached)
+
+		clock.Advance(30 * time.Second)
+		result, provenance, err := gocfg.LoadWithProvenance[remoteConfig](ctx, loader)
+		require.NoError(t, err)
+		assert.Equal(t, 3, inner.calls)
+		assert.Equal(t, "hunter2", result.Password)
+		assert.Equal(t, 5*time.Second, result.Timeout)
+		assert.Equal(t, 5432, result.Port)
+		assert.Equal(t, gocfg.Origin{Tag: "remote", Cached: true, CachedAt: time.Time{}}, provenance["Password"])
+
+		clock.Advance(30 * time.Second)
+		_, provenance, err = gocfg.LoadWithProvenance[remoteConfig](ctx, loader)
+		require.NoError(t, err)
+		assert.Equal(t, 6, inner.calls)
+		assert.False(t, provenance["Password"].Cached)
+	})
+
+	t.Run("Serves stale values while the loader fails", func(t *testing.T) {
+		clock := &fakeClock{}
+		inner := &outageLoader{}
+		loader := loaders.WithCache(inner, nil, 0, time.Hour, loaders.UsingClock(clock))
+
+		_, err := gocfg.Load[remoteConfig](ctx, loader)
+		require.NoError(t, err)
+
+		inner.down = true
+		clock.Advance(59 * time.Minute)
+		result, provenance, err := gocfg.LoadWithProvenance[remoteConfig](ctx, loader)
+		require.NoError(t, err)
+		assert.Equal(t, "hunter2", result.Password)
+		assert.True(t, provenance["Password"].Cached)
+
+		clock.Advance(time.Minute)
+		_, err = gocfg.Load[remoteConfig](ctx, loader)
+		require.ErrorIs(t, err, errUnavailable)
+	})
+
+	t.Run("Does not serve values the loader reports missing", func(t *testing.T) {
+		inner := &outageLoader{}
+		loader := loaders.WithCache(inner, nil, 0, time.Hour, loaders.UsingClock(&fakeClock{}))
+
+		result, err := gocfg.Load[struct {
+			Value string `remote:"missing"`
+		}](ctx, loader)
+
+		require.NoError(t, err)
+		assert.Empty(t, result.Value)
+	})
+
+	t.Run("Does not serve values in place of invalid ones", func(t *testing.T) {
+		text := "5432"
+		inner := &MockLoader{
+			NameToReturn: "remote",
+			LoadFunc: func(_ context.Context, _ reflect.StructField, value reflect.Value, _ string) error {
+				return utils.SetFieldValue(value, text)
+			},
+		}
+		loader := loaders.WithCache(inner, nil, 0, time.Hour, loaders.UsingClock(&fakeClock{}))
+
+		type config struct {
+			Port int `remote:"port"`
+		}
+
+		_, err := gocfg.Load[config](ctx, loader)
+		require.NoError(t, err)
+
+		text = "not-a-port"
+		_, err = gocfg.Load[config](ctx, loader)
+		require.ErrorIs(t, err, utils.ErrInvalidValue)
+	})
+
+	t.Run("Falls back to the store after a restart", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "cache")
+		key := bytes.Repeat([]byte{1}, 32)
+		clock := &fakeClock{}
+
+		loader := loaders.WithCache(&outageLoader{}, loaders.NewFileStore(path, key), 0, time.Hour, loaders.UsingClock(clock))
+		_, err := gocfg.Load[remoteConfig](ctx, loader)
+		require.NoError(t, err)
+
+		// The next process finds the backend down
+		clock.Advance(time.Minute)
+		restarted := loaders.WithCache(&outageLoader{down: true}, loaders.NewFileStore(path, key), 0, time.Hour, loaders.UsingClock(clock))
+		result, provenance, err := gocfg.LoadWithProvenance[remoteConfig](ctx, restarted)
+		require.NoError(t, err)
+		assert.Equal(t, "hunter2", result.Password)
+		assert.Equal(t, 5432, result.Port)
+		assert.True(t, provenance["Port"].Cached)
+
+		// Without the key the cache can't be read
+		wrongKey := bytes.Repeat([]byte{2}, 32)
+		locked := loaders.WithCache(&outageLoader{down: true}, loaders.NewFileStore(path, wrongKey), 0, time.Hour, loaders.UsingClock(clock))
+		_, err = gocfg.Load[remoteConfig](ctx, locked)
+		require.ErrorIs(t, err, errUnavailable)
+	})
+
+	t.Run("Caches batches", func(t *testing.T) {
+		inner := &batchingLoader{}
+		loader := loaders.WithCache(inner, nil, time.Minute, time.Hour, loaders.UsingClock(&fakeClock{}))
+
+		type config struct {
+			A string `flaky:"a"`
+			B string `flaky:"b"`
+		}
+
+		_, err := gocfg.Load[config](ctx, loader)
+		require.NoError(t, err)
+		result, provenance, err := gocfg.LoadWithProvenance[config](ctx, loader)
+		require.NoError(t, err)
+
+		assert.Equal(t, "b", result.B)
+		assert.True(t, provenance["B"].Cached)
+		assert.Len(t, inner.batches, 1)
+	})
+
+	t.Run("Writes the store once per load", func(t *testing.T) {
+		store := &countingStore{entries: make(map[string]loaders.CacheEntry)}
+		_, err := gocfg.Load[remoteConfig](ctx, loaders.WithCache(&outageLoader{}, store, 0, time.Hour))
+		require.NoError(t, err)
+		assert.Equal(t, 1, store.puts)
+		assert.Len(t, store.entries, 3)
+
+		store = &countingStore{entries: make(map[string]loaders.CacheEntry)}
+		_, err = gocfg.Load[struct {
+			A string `flaky:"a"`
+			B string `flaky:"b"`
+		}](ctx, loaders.WithCache(&batchingLoader{}, store, 0, time.Hour))
+		require.NoError(t, err)
+		assert.Equal(t, 1, store.puts)
+		assert.Len(t, store.entries, 2)
+	})
+}
+
+// countingStore is an in-memory CacheStore counting writes
+type countingStore struct {
+	entries map[string]loaders.CacheEntry
+	puts    int
+}
+
+func (s *countingStore) Get(key string) (loaders.CacheEntry, bool, error) {
+	entry, exists := s.entries[key]
+	return entry, exists, nil
+}
+
+func (s *countingStore) Put(entries map[string]loaders.CacheEntry) error {
+	s.puts++
+	for key, entry := range entries {
+		s.entries[key] = entry
+	}
+	return nil
+}
+
+func TestFileStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache")
+	store := loaders.NewFileStore(path, bytes.Repeat([]byte{1}, 16))
+
+	_, exists, err := store.Get("key")
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	entry := loaders.CacheEntry{Value: []byte(`"top-secret"`), LoadedAt: time.Unix(1700000000, 0).UTC()}
+	require.NoError(t, store.Put(map[string]loaders.CacheEntry{"key": entry}))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "top-secret")
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+	stored, exists, err := loaders.NewFileStore(path, bytes.Repeat([]byte{1}, 16)).Get("key")
+	require.NoError(t, err)
+	assert.True(t, exists)
+	assert.Equal(t, entry, stored)
+
+	assert.Panics(t, func() { loaders.NewFileStore(path, []byte("short")) })
+}
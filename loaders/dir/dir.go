@@ -0,0 +1,240 @@
+package dir
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Gardego5/gocfg"
+	"github.com/Gardego5/gocfg/utils"
+)
+
+// dataLink is the symlink Kubernetes swaps to publish a new version of a
+// mounted Secret or ConfigMap atomically.
+const dataLink = "..data"
+
+// DirLoader loads configuration from directories holding one file per key,
+// such as Kubernetes Secret and ConfigMap volumes or Docker secrets
+func New() gocfg.Loader { return &loader{} }
+
+type loader struct{}
+
+func (*loader) GocfgLoaderName() string { return "dir" }
+func (*loader) GocfgEscapedTags() bool  { return true }
+
+// Load implements the Loader interface for mounted secret directories
+// Tag formats supported:
+// - "/run/secrets:db_password" - Read the file db_password in /run/secrets
+// - "/run/secrets" - Use the field name as the file name
+// - "/run/secrets:db_password?" - Optional file
+// - "@SecretsDir||:db_password" - Build the directory from other fields
+//
+// Surrounding whitespace is trimmed from values, except for []byte fields
+// which receive the file contents unchanged. When the directory is managed
+// by Kubernetes, files are read through the ..data target, resolved once for
+// all the fields loaded together, so that their values come from the same
+// published version.
+func (l *loader) Load(
+	ctx context.Context,
+	field reflect.StructField, value reflect.Value,
+	resolvedTag string,
+) error {
+	ref, err := parseTag(field, resolvedTag)
+	if err != nil {
+		return err
+	}
+
+	data, errs := readKeys(ref.dir, []string{ref.name})
+	return ref.set(value, data[0], errs[0])
+}
+
+// LoadBatch implements gocfg.BatchLoader, reading the files of each
+// directory from a single version of it
+func (l *loader) LoadBatch(ctx context.Context, requests []gocfg.FieldRequest) []error {
+	errs := make([]error, len(requests))
+	refs := make([]keyRef, len(requests))
+
+	var dirs []string
+	indexes := make(map[string][]int) // Requests by directory
+	for i, request := range requests {
+		if refs[i], errs[i] = parseTag(request.Field, request.Tag); errs[i] != nil {
+			continue
+		}
+		if _, seen := indexes[refs[i].dir]; !seen {
+			dirs = append(dirs, refs[i].dir)
+		}
+		indexes[refs[i].dir] = append(indexes[refs[i].dir], i)
+	}
+
+	for _, dir := range dirs {
+		names := make([]string, len(indexes[dir]))
+		for j, i := range indexes[dir] {
+			names[j] = refs[i].name
+		}
+
+		data, readErrs := readKeys(dir, names)
+		for j, i := range indexes[dir] {
+			errs[i] = refs[i].set(requests[i].Value, data[j], readErrs[j])
+		}
+	}
+
+	return errs
+}
+
+// keyRef is a parsed tag
+type keyRef struct {
+	dir, name string
+	optional  bool
+}
+
+// parseTag parses the tag of a field
+func parseTag(field reflect.StructField, resolvedTag string) (ref keyRef, err error) {
+	// Handle special case - fully resolved reference or concatenation
+	if strings.HasPrefix(resolvedTag, "@") || strings.Contains(resolvedTag, "||") {
+		// At this point the tag should be resolved already
+		return ref, fmt.Errorf("unexpected unresolved tag: %s", resolvedTag)
+	}
+
+	tag := strings.TrimSpace(resolvedTag)
+
+	// Check if file is optional
+	tag, ref.optional = utils.CutTagSuffix(tag, "?")
+
+	// Check for file name specification, defaulting to the field name
+	dir, name, hasName := utils.CutTagLast(tag, ":")
+	if !hasName {
+		name = field.Name
+	}
+	ref.dir, ref.name = utils.UnescapeTag(strings.TrimSpace(dir)), utils.UnescapeTag(strings.TrimSpace(name))
+
+	if ref.dir == "" || ref.name == "" || strings.ContainsRune(ref.name, filepath.Separator) {
+		return ref, utils.InvalidTagError("invalid tag %q: expected directory:file", resolvedTag)
+	}
+
+	return ref, nil
+}
+
+// set sets a field from the contents of the referenced file, or reports the
+// error reading it
+func (ref keyRef) set(value reflect.Value, data []byte, err error) error {
+	if errors.Is(err, fs.ErrNotExist) {
+		return utils.NotFoundError(ref.optional, "file %s not found in %s", ref.name, ref.dir)
+	} else if err != nil {
+		return fmt.Errorf("failed to read %s from %s: %w", ref.name, ref.dir, err)
+	}
+
+	if _, ok := value.Addr().Interface().(*[]byte); ok {
+		value.SetBytes(data)
+		return nil
+	}
+
+	return utils.SetFieldValue(value, strings.TrimSpace(string(data)))
+}
+
+// readKeys reads the files for keys in a directory. In a Kubernetes volume
+// they are all read from the directory ..data points to, resolving it again
+// once if that version is removed by a concurrent update before every file
+// was read.
+func readKeys(dir string, names []string) ([][]byte, []error) {
+	data := make([][]byte, len(names))
+	errs := make([]error, len(names))
+
+	for attempt := 0; ; attempt++ {
+		target, err := os.Readlink(filepath.Join(dir, dataLink))
+		if err != nil {
+			// Not a Kubernetes volume, read the files directly
+			for i, name := range names {
+				data[i], errs[i] = os.ReadFile(filepath.Join(dir, name))
+			}
+			return data, errs
+		}
+
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(dir, target)
+		}
+
+		swapped := false
+		for i, name := range names {
+			data[i], errs[i] = os.ReadFile(filepath.Join(target, name))
+			if errors.Is(errs[i], fs.ErrNotExist) {
+				if _, statErr := os.Stat(target); errors.Is(statErr, fs.ErrNotExist) {
+					swapped = true
+					break
+				}
+			}
+		}
+
+		if !swapped || attempt > 0 {
+			return data, errs
+		}
+		// The version was swapped out, resolve ..data again
+	}
+}
+
+// Watch polls dir every interval and sends on the returned channel whenever
+// its contents change, until ctx is done. In a Kubernetes volume a change is
+// a new ..data target; otherwise it is any file being added, removed or
+// modified. Notifications are dropped while a previous one is unread.
+func Watch(ctx context.Context, dir string, interval time.Duration) <-chan struct{} {
+	changes := make(chan struct{}, 1)
+	last := fingerprint(dir)
+
+	go func() {
+		defer close(changes)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			current := fingerprint(dir)
+			if current == last {
+				continue
+			}
+			last = current
+
+			select {
+			case changes <- struct{}{}:
+			default:
+			}
+		}
+	}()
+
+	return changes
+}
+
+// fingerprint summarizes the current version of a directory's contents
+func fingerprint(dir string) string {
+	if target, err := os.Readlink(filepath.Join(dir, dataLink)); err == nil {
+		return target
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "error: " + err.Error()
+	}
+
+	parts := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		parts = append(parts, fmt.Sprintf("%s:%d:%d", entry.Name(), info.Size(), info.ModTime().UnixNano()))
+	}
+	sort.Strings(parts)
+
+	return strings.Join(parts, "|")
+}
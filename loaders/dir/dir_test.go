@@ -0,0 +1,149 @@
+package dir_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/Gardego5/gocfg"
+	. "github.com/Gardego5/gocfg/loaders/dir"
+	"github.com/Gardego5/gocfg/loaders/env"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupSecretsDir creates a Docker style directory with one file per secret
+func setupSecretsDir(t *testing.T) string {
+	dir := t.TempDir()
+
+	files := map[string]string{
+		"db_password": "hunter2\n",
+		"db_port":     "5432\n",
+		"ca.pem":      "-----BEGIN CERTIFICATE-----\n",
+		"Username":    "admin",
+	}
+
+	for name, content := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600))
+	}
+
+	return dir
+}
+
+// publishVersion writes a new version of a Kubernetes style volume and swaps
+// ..data to point at it, the way the kubelet updates mounted Secrets
+func publishVersion(t *testing.T, dir, version string, files map[string]string) {
+	versionDir := filepath.Join(dir, "..version_"+version)
+	require.NoError(t, os.Mkdir(versionDir, 0o700))
+
+	for name, content := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(versionDir, name), []byte(content), 0o600))
+
+		link := filepath.Join(dir, name)
+		if _, err := os.Lstat(link); os.IsNotExist(err) {
+			require.NoError(t, os.Symlink(filepath.Join("..data", name), link))
+		}
+	}
+
+	tmpLink := filepath.Join(dir, "..data_tmp")
+	require.NoError(t, os.Symlink("..version_"+version, tmpLink))
+	require.NoError(t, os.Rename(tmpLink, filepath.Join(dir, "..data")))
+}
+
+func TestDirLoader(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Reads and trims files", func(t *testing.T) {
+		dir := setupSecretsDir(t)
+
+		t.Setenv("SECRETS_DIR", dir)
+
+		result, err := Load[struct {
+			SecretsDir string `env:"SECRETS_DIR"`
+			Password   string `dir:"@SecretsDir||:db_password"`
+			Port       int    `dir:"@SecretsDir||:db_port"`
+			CA         []byte `dir:"@SecretsDir||:ca.pem"`
+			Username   string `dir:"@SecretsDir"`
+		}](ctx, env.New(), New())
+
+		require.NoError(t, err)
+		assert.Equal(t, "hunter2", result.Password)
+		assert.Equal(t, 5432, result.Port)
+		assert.Equal(t, []byte("-----BEGIN CERTIFICATE-----\n"), result.CA)
+		assert.Equal(t, "admin", result.Username)
+	})
+
+	t.Run("Handles optional files", func(t *testing.T) {
+		t.Setenv("SECRETS_DIR", setupSecretsDir(t))
+
+		result, err := Load[struct {
+			SecretsDir string `env:"SECRETS_DIR"`
+			Token      string `dir:"@SecretsDir||:token?"`
+		}](ctx, env.New(), New())
+
+		require.NoError(t, err)
+		assert.Empty(t, result.Token)
+	})
+
+	t.Run("Errors on missing required files", func(t *testing.T) {
+		t.Setenv("SECRETS_DIR", setupSecretsDir(t))
+
+		_, err := Load[struct {
+			SecretsDir string `env:"SECRETS_DIR"`
+			Token      string `dir:"@SecretsDir||:token"`
+		}](ctx, env.New(), New())
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "token")
+	})
+
+	t.Run("Reads Kubernetes volumes through ..data", func(t *testing.T) {
+		dir := t.TempDir()
+		t.Setenv("SECRETS_DIR", dir)
+
+		type Config struct {
+			SecretsDir string `env:"SECRETS_DIR"`
+			Username   string `dir:"@SecretsDir||:username"`
+			Password   string `dir:"@SecretsDir||:password"`
+		}
+
+		publishVersion(t, dir, "1", map[string]string{"username": "user-1", "password": "pass-1"})
+
+		first, err := Load[Config](ctx, env.New(), New())
+		require.NoError(t, err)
+		assert.Equal(t, "user-1", first.Username)
+		assert.Equal(t, "pass-1", first.Password)
+
+		publishVersion(t, dir, "2", map[string]string{"username": "user-2", "password": "pass-2"})
+		require.NoError(t, os.RemoveAll(filepath.Join(dir, "..version_1")))
+
+		second, err := Load[Config](ctx, env.New(), New())
+		require.NoError(t, err)
+		assert.Equal(t, "user-2", second.Username)
+		assert.Equal(t, "pass-2", second.Password)
+	})
+
+	t.Run("Watches directories for changes", func(t *testing.T) {
+		dir := t.TempDir()
+		publishVersion(t, dir, "1", map[string]string{"password": "pass-1"})
+
+		watchCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		changes := Watch(watchCtx, dir, 10*time.Millisecond)
+
+		publishVersion(t, dir, "2", map[string]string{"password": "pass-2"})
+
+		select {
+		case <-changes:
+		case <-time.After(5 * time.Second):
+			t.Fatal("expected a change notification")
+		}
+
+		cancel()
+		for range changes {
+		}
+	})
+}
@@ -5,60 +5,380 @@ import (
 	"fmt"
 	"os"
 	"reflect"
+	"slices"
 	"strings"
 
 	"github.com/Gardego5/gocfg"
 	"github.com/Gardego5/gocfg/utils"
 )
 
+// fileSuffix is appended to a variable name to find the variable holding the
+// path of a file with its value, as in POSTGRES_PASSWORD_FILE.
+const fileSuffix = "_FILE"
+
+// Option configures the env loader
+type Option func(*loader)
+
+// WithFiles makes every field honor the _FILE convention: when VAR is not
+// set but VAR_FILE is, the value is read from the file VAR_FILE names. Fields
+// can opt in individually with the "file" tag option instead.
+func WithFiles() Option {
+	return func(l *loader) { l.files = true }
+}
+
+// WithPrefix prepends prefix to the name of every variable, as in
+// env.WithPrefix("MYAPP_").
+func WithPrefix(prefix string) Option {
+	return func(l *loader) { l.prefix = prefix }
+}
+
+// WithAutoNaming loads fields without a tag for any loader as optional
+// variables named after their field path, the same way as env:",".
+func WithAutoNaming() Option {
+	return func(l *loader) { l.autoNaming = true }
+}
+
+// WithExpansion expands ${VAR} and ${VAR:-default} in every value, including
+// defaults, from other environment variables. Config fields are referenced
+// with @ in the tag instead, which loads them first. Fields can opt in
+// individually with the "expand" tag option instead.
+func WithExpansion() Option {
+	return func(l *loader) { l.expand = true }
+}
+
+// WithStrict makes Load fail with utils.ErrUnknownVariable when environment
+// variables starting with the loader's prefix are not declared by any field,
+// suggesting the closest known name for each. It requires WithPrefix.
+func WithStrict() Option {
+	return func(l *loader) { l.strict = true }
+}
+
 // EnvLoader loads configuration from environment variables
-func New() gocfg.Loader { return &loader{} }
+func New(opts ...Option) gocfg.Loader {
+	l := &loader{}
 
-type loader struct{}
+	for _, opt := range opts {
+		opt(l)
+	}
+
+	if l.strict && l.prefix == "" {
+		panic("strict mode requires a prefix")
+	}
+
+	return l
+}
+
+type loader struct {
+	files      bool
+	prefix     string
+	autoNaming bool
+	expand     bool
+	strict     bool
+}
 
 func (*loader) GocfgLoaderName() string { return "env" }
+func (*loader) GocfgEscapedTags() bool  { return true }
+
+func (e *loader) GocfgUntaggedTag(reflect.StructField) (string, bool) {
+	return "?", e.autoNaming
+}
 
+// Load implements the Loader interface for environment variables
+// Tag formats supported:
+// - "VAR" - Required variable
+// - "VAR?" - Optional variable
+// - "VAR=default" - Variable with a default value, or "VAR=" for the zero value
+// - "VAR,file" - Also read the value from the file named by VAR_FILE
+// - "VAR,expand" - Expand ${OTHER} and ${OTHER:-default} in the value
+// - "," - Derive the name from the field path, so Database.Host reads
+// DATABASE_HOST. Options, "?" and defaults work the same, as in ",?".
+//
+// Names are prefixed with the loader's prefix and the envPrefix tags of the
+// enclosing struct fields. Derived names use the snake-cased field names of
+// enclosing struct fields without an envPrefix tag.
 func (e *loader) Load(
 	ctx context.Context,
 	field reflect.StructField, value reflect.Value,
 	resolvedTag string,
 ) error {
+	v, err := e.parseTag(resolvedTag)
+	if err != nil {
+		return err
+	}
+	envVar := e.variableName(ctx, field, v.name)
+
+	// Look up the environment variable
+	envValue, exists, err := lookup(envVar, v.readFiles)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		if !v.hasDefault {
+			return utils.NotFoundError(v.optional, "environment variable %s not set", envVar)
+		}
+		if v.defaultValue == "" {
+			return nil // An empty default leaves the zero value, as in COUNT=
+		}
+		envValue = v.defaultValue // Use default value
+	}
+
+	if v.expand {
+		if envValue, err = gocfg.Expand(envValue, expansionLookup(nil)); err != nil {
+			return fmt.Errorf("failed to expand environment variable %s: %w", envVar, err)
+		}
+	}
+
+	return utils.SetFieldValue(value, envValue)
+}
+
+// variable is a parsed tag
+type variable struct {
+	name         string // Empty to derive it from the field path
+	defaultValue string
+	hasDefault   bool
+	optional     bool
+	readFiles    bool
+	expand       bool
+}
+
+// parseTag parses the tag of a field
+func (e *loader) parseTag(resolvedTag string) (v variable, err error) {
 	// Parse the tag to determine what to load
 	tag := strings.TrimSpace(resolvedTag)
 
 	// Handle special case - fully resolved reference or concatenation
 	if strings.HasPrefix(tag, "@") || strings.Contains(tag, "||") {
 		// At this point the tag should be resolved already
-		return fmt.Errorf("unexpected unresolved tag: %s", tag)
+		return v, fmt.Errorf("unexpected unresolved tag: %s", tag)
 	}
 
 	// Parse the tag format (VAR, VAR?, VAR=default)
-	var envVar string
-	var defaultValue string
-	var isOptional bool
+	envVar, defaultValue, hasDefault := utils.CutTag(tag, "=")
+	v.defaultValue = utils.UnescapeTag(defaultValue) // Preserve spaces in default value
+	v.hasDefault = hasDefault
 
-	if strings.HasSuffix(tag, "?") {
-		isOptional = true
-		envVar = strings.TrimSuffix(tag, "?")
-	} else if idx := strings.Index(tag, "="); idx >= 0 {
-		envVar = strings.TrimSpace(tag[:idx])
-		defaultValue = tag[idx+1:] // Preserve spaces in default value
-	} else {
-		envVar = tag
+	if !hasDefault {
+		envVar, v.optional = utils.CutTagSuffix(envVar, "?")
 	}
 
-	// Look up the environment variable
-	envValue, exists := os.LookupEnv(envVar)
-	if !exists {
-		if isOptional {
-			return nil // Optional field, no error if not set
+	// Parse tag options (VAR,file,expand)
+	v.readFiles, v.expand = e.files, e.expand
+	envVar, options, _ := utils.CutTag(envVar, ",")
+	v.name = utils.UnescapeTag(strings.TrimSpace(envVar))
+	for _, option := range utils.SplitTag(options, ",") {
+		switch option = strings.TrimSpace(option); option {
+		case "":
+		case "file":
+			v.readFiles = true
+		case "expand":
+			v.expand = true
+		default:
+			return v, utils.InvalidTagError("unknown option %q for environment variable %s", option, v.name)
 		}
-		if defaultValue != "" {
-			// Use default value
-			return utils.SetFieldValue(value, defaultValue)
+	}
+
+	return v, nil
+}
+
+// expansionLookup looks up names in the environment, adding them to known
+// when it isn't nil
+func expansionLookup(known map[string]bool) func(name string) (string, bool) {
+	return func(name string) (string, bool) {
+		if known != nil {
+			known[name] = true
 		}
-		return fmt.Errorf("%w: environment variable %s not set", utils.ErrMissingRequired, envVar)
+		return os.LookupEnv(name)
 	}
+}
 
-	return utils.SetFieldValue(value, envValue)
+// GocfgFinalize reports the variables with the loader's prefix that no field
+// declares, in strict mode. Every field with an env tag counts, or without
+// any tag when auto naming, even if another loader supplied it or its when
+// tag skipped it.
+func (e *loader) GocfgFinalize(ctx context.Context) error {
+	if !e.strict {
+		return nil
+	}
+
+	known, err := e.knownVariables(ctx)
+	if err != nil {
+		return err
+	}
+
+	var unknown []string
+	for _, entry := range os.Environ() {
+		name, _, _ := strings.Cut(entry, "=")
+		if strings.HasPrefix(name, e.prefix) && !known[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) == 0 {
+		return nil
+	}
+	slices.Sort(unknown)
+
+	knownNames := make([]string, 0, len(known))
+	for name := range known {
+		knownNames = append(knownNames, name)
+	}
+	slices.Sort(knownNames)
+
+	for i, name := range unknown {
+		if suggestion := closest(name, knownNames, e.prefix); suggestion != "" {
+			unknown[i] = fmt.Sprintf("%s (did you mean %s?)", name, suggestion)
+		}
+	}
+
+	return fmt.Errorf("%w: %s", utils.ErrUnknownVariable, strings.Join(unknown, ", "))
+}
+
+// knownVariables returns the names of the variables the fields of the
+// configuration declare, including the _FILE variables and the variables
+// their values expand
+func (e *loader) knownVariables(ctx context.Context) (map[string]bool, error) {
+	fields, err := gocfg.DeclaredFields(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	known := make(map[string]bool)
+	for _, field := range fields {
+		v, err := e.parseTag(field.Tag)
+		if err != nil {
+			continue // Reported by Load, unless the field was skipped
+		}
+
+		envVar := e.variableName(field.Context(), field.Field, v.name)
+		known[envVar] = true
+		if v.readFiles {
+			known[envVar+fileSuffix] = true
+		}
+
+		if v.expand {
+			envValue, exists, _ := lookup(envVar, v.readFiles)
+			if !exists {
+				envValue = v.defaultValue
+			}
+			_, _ = gocfg.Expand(envValue, expansionLookup(known))
+		}
+	}
+
+	return known, nil
+}
+
+// closest returns the candidate nearest to name by edit distance, or "" when
+// none is close enough to be a likely typo. The shared prefix is left out of
+// the comparison, so short names aren't matched to just anything.
+func closest(name string, candidates []string, prefix string) string {
+	name = strings.TrimPrefix(name, prefix)
+	best, bestDistance := "", len(name)/3+1
+	for _, candidate := range candidates {
+		if d := levenshtein(name, strings.TrimPrefix(candidate, prefix)); d < bestDistance {
+			best, bestDistance = candidate, d
+		}
+	}
+	return best
+}
+
+// levenshtein returns the number of single byte insertions, deletions and
+// substitutions needed to turn a into b
+func levenshtein(a, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+
+	return prev[len(b)]
+}
+
+// variableName returns the full name of a field's variable, deriving it from
+// the field path when the tag gives no name
+func (e *loader) variableName(ctx context.Context, field reflect.StructField, name string) string {
+	path := gocfg.FieldPath(ctx)
+	if len(path) == 0 {
+		path = []reflect.StructField{field}
+	}
+
+	var fullName strings.Builder
+	fullName.WriteString(e.prefix)
+
+	for _, parent := range path[:len(path)-1] {
+		if prefix, ok := parent.Tag.Lookup("envPrefix"); ok {
+			fullName.WriteString(prefix)
+		} else if name == "" && !parent.Anonymous {
+			fullName.WriteString(toScreamingSnake(parent.Name) + "_")
+		}
+	}
+
+	if name == "" {
+		name = toScreamingSnake(field.Name)
+	}
+	fullName.WriteString(name)
+
+	return fullName.String()
+}
+
+// toScreamingSnake converts a Go identifier to an environment variable name,
+// keeping acronyms together: DatabaseHost becomes DATABASE_HOST and APIKey
+// becomes API_KEY.
+func toScreamingSnake(name string) string {
+	var snake strings.Builder
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if i > 0 && isUpper(c) {
+			prev := name[i-1]
+			nextIsLower := i+1 < len(name) && isLower(name[i+1])
+			if isLower(prev) || isDigit(prev) || (isUpper(prev) && nextIsLower) {
+				snake.WriteByte('_')
+			}
+		}
+		if isLower(c) {
+			c -= 'a' - 'A'
+		}
+		snake.WriteByte(c)
+	}
+	return snake.String()
+}
+
+func isUpper(c byte) bool { return c >= 'A' && c <= 'Z' }
+func isLower(c byte) bool { return c >= 'a' && c <= 'z' }
+func isDigit(c byte) bool { return c >= '0' && c <= '9' }
+
+// lookup returns the value of an environment variable. When readFiles is set
+// and only VAR_FILE is set, the value is read from the file it names instead.
+func lookup(envVar string, readFiles bool) (string, bool, error) {
+	envValue, exists := os.LookupEnv(envVar)
+	if !readFiles {
+		return envValue, exists, nil
+	}
+
+	filePath, fileExists := os.LookupEnv(envVar + fileSuffix)
+	if !fileExists {
+		return envValue, exists, nil
+	}
+	if exists {
+		return "", false, fmt.Errorf("%w: both %s and %s%s are set",
+			utils.ErrConflictingSources, envVar, envVar, fileSuffix)
+	}
+
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to read %s%s: %w", envVar, fileSuffix, err)
+	}
+
+	// Drop the trailing newline most editors and `echo` leave in the file
+	return strings.TrimRight(string(data), "\r\n"), true, nil
 }
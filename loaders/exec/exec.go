@@ -0,0 +1,303 @@
+package exec
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Gardego5/gocfg"
+	"github.com/Gardego5/gocfg/utils"
+)
+
+// defaultTimeout bounds how long a command may run unless WithTimeout says
+// otherwise
+const defaultTimeout = 30 * time.Second
+
+// Option configures the exec loader
+type Option func(*loader)
+
+// WithAllowed allows commands to run the given binaries, by name as found in
+// PATH, as in "op", or by path, as in "/usr/local/bin/op". No binary is
+// allowed by default.
+func WithAllowed(binaries ...string) Option {
+	return func(l *loader) {
+		for _, binary := range binaries {
+			if strings.ContainsRune(binary, filepath.Separator) {
+				binary = absolutePath(binary)
+			}
+			l.allowed[binary] = true
+		}
+	}
+}
+
+// WithTimeout kills commands running longer than timeout, 30s by default
+func WithTimeout(timeout time.Duration) Option {
+	return func(l *loader) { l.timeout = timeout }
+}
+
+// WithCacheTTL reuses the output of a command for ttl, across fields and Load
+// calls, instead of running it again. Outputs are not cached by default.
+func WithCacheTTL(ttl time.Duration) Option {
+	return func(l *loader) { l.cacheTTL = ttl }
+}
+
+// ExecLoader loads configuration from the output of commands, such as
+// credential helpers
+func New(opts ...Option) gocfg.Loader {
+	l := &loader{
+		allowed: make(map[string]bool),
+		timeout: defaultTimeout,
+		outputs: make(map[string]output),
+	}
+
+	for _, opt := range opts {
+		opt(l)
+	}
+
+	return l
+}
+
+type loader struct {
+	allowed  map[string]bool
+	timeout  time.Duration
+	cacheTTL time.Duration
+
+	mu      sync.Mutex
+	outputs map[string]output // Cached outputs by command line
+}
+
+// output is the cached output of a command
+type output struct {
+	stdout []byte
+	ranAt  time.Time
+}
+
+func (*loader) GocfgLoaderName() string { return "exec" }
+func (*loader) GocfgRawTags() bool      { return true }
+
+// Load implements the Loader interface for commands
+// Tag formats supported:
+// - "op read op://vault/item/field" - Run a command, splitting arguments at
+// whitespace
+// - "pass show 'my secrets/db'" - Quote arguments containing whitespace
+// - "vault-helper --tenant @Tenant" - Use other fields as arguments, which
+// are never split at whitespace
+// - "gcloud auth print-access-token?" - Optional value, when the command fails
+//
+// Arguments have the tag syntax of other loaders, so "c escapes the character
+// c, as in "@ or "?, and references can be formatted and concatenated within
+// an argument, as in --port=@Port:%d. Commands run without a shell, and only
+// if their binary is allowed with WithAllowed. A command exiting with an error
+// fails the load with its stderr, unless the tag is optional. Surrounding
+// whitespace is trimmed from the output, except for []byte fields which
+// receive it unchanged.
+func (l *loader) Load(
+	ctx context.Context,
+	field reflect.StructField, value reflect.Value,
+	tag string,
+) error {
+	cmd, err := parseCommand(tag)
+	if err != nil {
+		return err
+	}
+
+	args, err := cmd.resolve(ctx)
+	if err != nil {
+		return err
+	}
+
+	stdout, err := l.run(ctx, args)
+	if err != nil {
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) {
+			return err
+		}
+		if cmd.optional {
+			return utils.NotFoundError(true, "command %s failed: %v", args[0], err)
+		}
+		return fmt.Errorf("command %s failed: %w", args[0], err)
+	}
+
+	if _, ok := value.Addr().Interface().(*[]byte); ok {
+		value.SetBytes(bytes.Clone(stdout)) // Cached outputs are shared
+		return nil
+	}
+
+	return utils.SetFieldValue(value, strings.TrimSpace(string(stdout)))
+}
+
+// GocfgDependencies implements gocfg.DependencyDeclarer, returning the fields
+// the command's arguments reference
+func (*loader) GocfgDependencies(_ reflect.StructField, tag string) []string {
+	deps, err := gocfg.TagReferences(tag)
+	if err != nil {
+		return nil // Reported by Load
+	}
+	return deps
+}
+
+// run runs a command, or returns its cached output
+func (l *loader) run(ctx context.Context, args []string) ([]byte, error) {
+	binary, err := l.binary(args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	key := strings.Join(args, "\x00")
+	if l.cacheTTL > 0 {
+		l.mu.Lock()
+		cached, exists := l.outputs[key]
+		l.mu.Unlock()
+		if exists && time.Since(cached.ranAt) < l.cacheTTL {
+			return cached.stdout, nil
+		}
+	}
+
+	runCtx, cancel := context.WithTimeout(ctx, l.timeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(runCtx, binary, args[1:]...)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	cmd.WaitDelay = time.Second // Don't wait on children holding the pipes
+
+	ranAt := time.Now()
+	if err := cmd.Run(); err != nil {
+		if err := runCtx.Err(); err != nil {
+			if ctx.Err() == nil {
+				return nil, fmt.Errorf("command %s timed out after %s", args[0], l.timeout)
+			}
+			return nil, fmt.Errorf("command %s canceled: %w", args[0], err)
+		}
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			const maxMessage = 200
+			if len(message) > maxMessage {
+				message = message[:maxMessage] + "..."
+			}
+			return nil, fmt.Errorf("%w: %s", err, message)
+		}
+		return nil, err
+	}
+
+	if l.cacheTTL > 0 {
+		l.mu.Lock()
+		l.outputs[key] = output{stdout: stdout.Bytes(), ranAt: ranAt}
+		l.mu.Unlock()
+	}
+
+	return stdout.Bytes(), nil
+}
+
+// binary returns the path of an allowed binary
+func (l *loader) binary(name string) (string, error) {
+	path, err := exec.LookPath(name)
+	if err != nil {
+		return "", fmt.Errorf("command %s not found: %w", name, err)
+	}
+
+	if (!strings.ContainsRune(name, filepath.Separator) && l.allowed[name]) || l.allowed[absolutePath(path)] {
+		return path, nil
+	}
+
+	return "", fmt.Errorf("command %s is not allowed, see exec.WithAllowed", name)
+}
+
+// absolutePath returns the cleaned absolute form of a path
+func absolutePath(path string) string {
+	if absolute, err := filepath.Abs(path); err == nil {
+		return absolute
+	}
+	return filepath.Clean(path)
+}
+
+// command is a parsed tag
+type command struct {
+	args     []string // Arguments in the tag syntax, resolved when run
+	optional bool
+}
+
+// parseCommand parses a tag into arguments
+func parseCommand(tag string) (cmd command, err error) {
+	tag, cmd.optional = utils.CutTagSuffix(strings.TrimSpace(tag), "?")
+
+	cmd.args = splitArgs(tag)
+	if len(cmd.args) == 0 {
+		return cmd, errors.New("invalid command: empty tag")
+	}
+
+	for i, arg := range cmd.args {
+		references, err := gocfg.TagReferences(arg)
+		if err != nil {
+			return cmd, utils.InvalidTagError("invalid command %q: %w", tag, err)
+		}
+		if i == 0 && len(references) > 0 {
+			return cmd, utils.InvalidTagError("invalid command %q: the binary can't reference fields", tag)
+		}
+	}
+
+	return cmd, nil
+}
+
+// splitArgs splits a tag at whitespace that is neither escaped nor quoted.
+// The arguments keep their escapes and quotes, for gocfg.ResolveTag.
+func splitArgs(tag string) []string {
+	var args []string
+	start := -1
+	quoted := false
+
+	for i := 0; i < len(tag); i++ {
+		c := tag[i]
+		if !quoted && (c == ' ' || c == '\t' || c == '\n') {
+			if start >= 0 {
+				args = append(args, tag[start:i])
+				start = -1
+			}
+			continue
+		}
+
+		if start < 0 {
+			start = i
+		}
+		switch {
+		case c == utils.TagEscape:
+			i++ // Escapes the next character, even a quote or whitespace
+		case c == '\'' && (quoted || i == start || !isWordChar(tag[i-1])):
+			quoted = !quoted // Not an apostrophe, as in it's
+		}
+	}
+	if start >= 0 {
+		args = append(args, tag[start:])
+	}
+
+	return args
+}
+
+// resolve returns the arguments of a command, with the values of the fields
+// it references
+func (cmd command) resolve(ctx context.Context) ([]string, error) {
+	args := make([]string, len(cmd.args))
+	for i, arg := range cmd.args {
+		resolved, err := gocfg.ResolveTag(ctx, arg)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = utils.UnescapeTag(resolved)
+	}
+	return args, nil
+}
+
+// isWordChar returns true if c is a letter, a digit or _, after which a ' is
+// an apostrophe
+func isWordChar(c byte) bool {
+	return (c >= 'a' && c <= 'z') ||
+		(c >= 'A' && c <= 'Z') ||
+		(c >= '0' && c <= '9') ||
+		c == '_'
+}
@@ -0,0 +1,204 @@
+package exec_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/Gardego5/gocfg"
+	"github.com/Gardego5/gocfg/loaders/env"
+	. "github.com/Gardego5/gocfg/loaders/exec"
+	"github.com/Gardego5/gocfg/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// helper is a stand-in credential helper: it prints its arguments one per
+// line in brackets, counting its runs in the file named by HELPER_RUNS
+const helper = `#!/bin/sh
+[ -n "$HELPER_RUNS" ] && echo run >> "$HELPER_RUNS"
+case "$1" in
+fail) echo "vault is locked" >&2; exit 1 ;;
+hang) exec sleep 10 ;;
+esac
+for arg in "$@"; do echo "[$arg]"; done
+`
+
+// writeHelper writes the helper script to a directory added to PATH,
+// returning its path
+func writeHelper(t *testing.T) string {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "helper")
+	require.NoError(t, os.WriteFile(path, []byte(helper), 0o755))
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	return path
+}
+
+func TestExecLoader(t *testing.T) {
+	ctx := context.Background()
+	path := writeHelper(t)
+
+	t.Run("Loads the output of commands", func(t *testing.T) {
+		result, err := Load[struct {
+			Token string `exec:"helper read op://vault/item/field"`
+			Raw   []byte `exec:"helper raw"`
+		}](ctx, New(WithAllowed("helper")))
+
+		require.NoError(t, err)
+		assert.Equal(t, "[read]\n[op://vault/item/field]", result.Token)
+		assert.Equal(t, []byte("[raw]\n"), result.Raw)
+	})
+
+	t.Run("Allows binaries by path", func(t *testing.T) {
+		result, err := Load[struct {
+			Token string `exec:"helper token"`
+		}](ctx, New(WithAllowed(path)))
+
+		require.NoError(t, err)
+		assert.Equal(t, "[token]", result.Token)
+	})
+
+	t.Run("Rejects binaries that are not allowed", func(t *testing.T) {
+		_, err := Load[struct {
+			Token string `exec:"helper token"`
+		}](ctx, New(WithAllowed("op", "pass")))
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "command helper is not allowed")
+
+		_, err = Load[struct {
+			Token string `exec:"helper token"`
+		}](ctx, New())
+		require.Error(t, err)
+	})
+
+	t.Run("Quotes and escapes arguments", func(t *testing.T) {
+		result, err := Load[struct {
+			Token string `exec:"helper 'my secrets/db' a\"@b \"? it's"`
+		}](ctx, New(WithAllowed("helper")))
+
+		require.NoError(t, err)
+		assert.Equal(t, "[my secrets/db]\n[a@b]\n[?]\n[it's]", result.Token)
+	})
+
+	t.Run("Passes referenced fields as single arguments", func(t *testing.T) {
+		t.Setenv("VAULT_ITEM", "db password")
+
+		result, err := Load[struct {
+			Token string `exec:"helper --item=@Vault.Item --tenant @Tenant"`
+			Vault struct {
+				Item string `env:"VAULT_ITEM"`
+			}
+			Tenant string `env:"TENANT=acme; rm -rf /"`
+		}](ctx, New(WithAllowed("helper")), env.New())
+
+		require.NoError(t, err)
+		assert.Equal(t, "[--item=db password]\n[--tenant]\n[acme; rm -rf /]", result.Token)
+	})
+
+	t.Run("Formats and concatenates references within arguments", func(t *testing.T) {
+		t.Setenv("PORT", "80")
+		t.Setenv("HOST", "db internal")
+
+		result, err := Load[struct {
+			Token string `exec:"helper --addr=@Host||':'||@Port:%05d @Host|upper"`
+			Port  int    `env:"PORT"`
+			Host  string `env:"HOST"`
+		}](ctx, New(WithAllowed("helper")), env.New())
+
+		require.NoError(t, err)
+		assert.Equal(t, "[--addr=db internal:00080]\n[DB INTERNAL]", result.Token)
+	})
+
+	t.Run("Reports failing commands", func(t *testing.T) {
+		_, err := Load[struct {
+			Token string `exec:"helper fail"`
+		}](ctx, New(WithAllowed("helper")))
+
+		require.Error(t, err)
+		assert.NotErrorIs(t, err, utils.ErrNotFound)
+		assert.Contains(t, err.Error(), "vault is locked")
+
+		_, err = Load[struct {
+			Token string `exec:"helper fail" default:"fallback"`
+		}](ctx, New(WithAllowed("helper")))
+		assert.ErrorContains(t, err, "vault is locked")
+
+		result, err := Load[struct {
+			Token    string `exec:"helper fail?"`
+			Fallback string `exec:"helper fail?" default:"fallback"`
+		}](ctx, New(WithAllowed("helper")))
+
+		require.NoError(t, err)
+		assert.Empty(t, result.Token)
+		assert.Equal(t, "fallback", result.Fallback)
+	})
+
+	t.Run("Kills commands that time out", func(t *testing.T) {
+		start := time.Now()
+		_, err := Load[struct {
+			Token string `exec:"helper hang"`
+		}](ctx, New(WithAllowed("helper"), WithTimeout(100*time.Millisecond)))
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "timed out after 100ms")
+		assert.Less(t, time.Since(start), 5*time.Second)
+	})
+
+	t.Run("Stops commands when the load is canceled", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+		defer cancel()
+
+		_, err := Load[struct {
+			Token string `exec:"helper hang?"`
+		}](ctx, New(WithAllowed("helper")))
+
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.NotContains(t, err.Error(), "timed out after")
+	})
+
+	t.Run("Caches outputs", func(t *testing.T) {
+		runs := filepath.Join(t.TempDir(), "runs")
+		t.Setenv("HELPER_RUNS", runs)
+
+		type config struct {
+			A string `exec:"helper token"`
+			B string `exec:"helper token"`
+		}
+
+		loader := New(WithAllowed("helper"), WithCacheTTL(time.Hour))
+		for i := 0; i < 2; i++ {
+			result, err := Load[config](ctx, loader)
+			require.NoError(t, err)
+			assert.Equal(t, "[token]", result.B)
+		}
+
+		data, err := os.ReadFile(runs)
+		require.NoError(t, err)
+		assert.Equal(t, 1, strings.Count(string(data), "run"))
+	})
+
+	t.Run("Errors on invalid commands", func(t *testing.T) {
+		loader := New(WithAllowed("helper"))
+
+		_, err := Load[struct {
+			Token string `exec:"helper 'unterminated"`
+		}](ctx, loader)
+		require.Error(t, err)
+
+		_, err = Load[struct {
+			Token string `exec:"helper @"`
+		}](ctx, loader)
+		require.Error(t, err)
+
+		_, err = Load[struct {
+			Binary string `env:"BINARY=helper"`
+			Token  string `exec:"@Binary token"`
+		}](ctx, loader, env.New())
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "the binary can't reference fields")
+	})
+}
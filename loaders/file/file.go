@@ -0,0 +1,220 @@
+package file
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Gardego5/gocfg"
+	"github.com/Gardego5/gocfg/utils"
+)
+
+// Option configures the file loader
+type Option func(*loader)
+
+// WithFS reads files from fsys instead of the operating system's file system,
+// for example from an embed.FS.
+func WithFS(fsys fs.FS) Option {
+	return func(l *loader) {
+		l.readFile = func(name string) ([]byte, error) { return fs.ReadFile(fsys, name) }
+		l.stat = func(name string) (fs.FileInfo, error) { return fs.Stat(fsys, name) }
+	}
+}
+
+// WithDir resolves relative paths against dir instead of the working
+// directory.
+func WithDir(dir string) Option {
+	return func(l *loader) { l.dir = dir }
+}
+
+// FileLoader loads configuration from JSON, YAML, TOML, INI and dotenv files
+func New(opts ...Option) gocfg.Loader {
+	l := &loader{
+		readFile:  os.ReadFile,
+		stat:      os.Stat,
+		documents: make(map[string]document),
+	}
+
+	for _, opt := range opts {
+		opt(l)
+	}
+
+	return l
+}
+
+// document is a parsed file, kept until the file changes on disk
+type document struct {
+	modTime time.Time
+	size    int64
+	root    any
+}
+
+type loader struct {
+	dir      string
+	readFile func(name string) ([]byte, error)
+	stat     func(name string) (fs.FileInfo, error)
+
+	mu        sync.Mutex
+	documents map[string]document
+}
+
+func (*loader) GocfgLoaderName() string { return "file" }
+func (*loader) GocfgEscapedTags() bool  { return true }
+
+// Load implements the Loader interface for configuration files
+// Tag formats supported:
+// - "config.yaml" - Decode the whole document into the field
+// - "config.yaml:server.port" - Get a value by its dotted key path
+// - "config.yaml:servers.0.host" - Index into arrays by position
+// - "config.yaml:server.port?" - Optional file or key
+// - "@ConfigPath||:server.port" - Build the path from other fields
+//
+// The format is chosen by extension: .json, .yaml, .yml, .toml, .ini and
+// .env (including names such as .env.local). Objects and arrays are decoded
+// into struct, map and slice fields by the format's own decoder, so YAML and
+// TOML fields are matched by their yaml and toml struct tags.
+func (l *loader) Load(
+	ctx context.Context,
+	field reflect.StructField, value reflect.Value,
+	resolvedTag string,
+) error {
+	// Handle special case - fully resolved reference or concatenation
+	if strings.HasPrefix(resolvedTag, "@") || strings.Contains(resolvedTag, "||") {
+		// At this point the tag should be resolved already
+		return fmt.Errorf("unexpected unresolved tag: %s", resolvedTag)
+	}
+
+	tag := strings.TrimSpace(resolvedTag)
+
+	// Check if value is optional
+	tag, isOptional := utils.CutTagSuffix(tag, "?")
+
+	// Split the file path from the key path, which follows the extension so
+	// paths like C:\cfg.yaml aren't split
+	filePath, keyPath := tag, ""
+	if before, after, found := utils.CutTagLast(tag, ":"); found && hasExtension(before) {
+		filePath, keyPath = before, after
+	}
+	filePath = utils.UnescapeTag(strings.TrimSpace(filePath))
+	keyPath = utils.UnescapeTag(strings.TrimSpace(keyPath))
+
+	if filePath == "" {
+		return utils.InvalidTagError("invalid tag %q: expected path[:key]", resolvedTag)
+	}
+
+	root, err := l.document(filePath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return utils.NotFoundError(isOptional, "file %s not found", filePath)
+	} else if err != nil {
+		return err
+	}
+
+	node, exists := lookup(root, keyPath)
+	if !exists {
+		return utils.NotFoundError(isOptional, "key %s not found in file %s", keyPath, filePath)
+	}
+
+	return decode(format(filePath), node, value)
+}
+
+// hasExtension reports whether the path names a file with an extension, or
+// a dotenv file
+func hasExtension(filePath string) bool {
+	base := filepath.Base(strings.TrimSpace(utils.UnescapeTag(filePath)))
+	return filepath.Ext(base) != "" || base == ".env"
+}
+
+// document returns the parsed contents of a file, parsing it again only when
+// its modification time or size changed since it was last read.
+func (l *loader) document(filePath string) (any, error) {
+	if l.dir != "" && !filepath.IsAbs(filePath) {
+		filePath = filepath.Join(l.dir, filePath)
+	}
+
+	info, err := l.stat(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	l.mu.Lock()
+	cached, isCached := l.documents[filePath]
+	l.mu.Unlock()
+	if isCached && cached.modTime.Equal(info.ModTime()) && cached.size == info.Size() {
+		return cached.root, nil
+	}
+
+	data, err := l.readFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	root, err := parse(filePath, data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse file %s: %w", filePath, err)
+	}
+
+	l.mu.Lock()
+	l.documents[filePath] = document{modTime: info.ModTime(), size: info.Size(), root: root}
+	l.mu.Unlock()
+
+	return root, nil
+}
+
+// lookup walks a parsed document along a dotted key path. Path segments index
+// into objects by key and into arrays by position.
+func lookup(root any, keyPath string) (any, bool) {
+	if keyPath == "" {
+		return root, true
+	}
+
+	node := root
+	for _, segment := range strings.Split(keyPath, ".") {
+		switch n := node.(type) {
+		case map[string]any:
+			next, exists := n[segment]
+			if !exists {
+				return nil, false
+			}
+			node = next
+
+		case map[any]any: // YAML mappings with keys other than strings
+			found := false
+			for key, next := range n {
+				if fmt.Sprint(key) == segment {
+					node, found = next, true
+					break
+				}
+			}
+			if !found {
+				return nil, false
+			}
+
+		case []any:
+			index, err := strconv.Atoi(segment)
+			if err != nil || index < 0 || index >= len(n) {
+				return nil, false
+			}
+			node = n[index]
+
+		case []map[string]any: // TOML arrays of tables
+			index, err := strconv.Atoi(segment)
+			if err != nil || index < 0 || index >= len(n) {
+				return nil, false
+			}
+			node = n[index]
+
+		default:
+			return nil, false
+		}
+	}
+
+	return node, true
+}
@@ -0,0 +1,228 @@
+package file_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	. "github.com/Gardego5/gocfg"
+	"github.com/Gardego5/gocfg/loaders/env"
+	. "github.com/Gardego5/gocfg/loaders/file"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupConfigDir(t *testing.T) string {
+	dir := t.TempDir()
+
+	files := map[string]string{
+		"config.json": `{"server": {"host": "json-host", "port": 8080}, "replicas": [{"host": "r1"}, {"host": "r2"}]}`,
+		"config.yaml": "server:\n  host: yaml-host\n  port: 8081\ntags: [a, b]\n",
+		"config.toml": "[server]\nhost = \"toml-host\"\nport = 8082\n\n[[replicas]]\nhost = \"t1\"\n",
+		"config.ini":  "name = app\n\n[server]\nhost = ini-host\nport = 8083\n",
+		".env":        "# comment\nexport DB_HOST=env-host\nDB_PORT=8084 # trailing\nDB_NAME=\"app db\"\n",
+	}
+
+	for name, content := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600))
+	}
+
+	return dir
+}
+
+type serverConfig struct {
+	Host string `json:"host"`
+	Port int    `json:"port"`
+}
+
+func TestFileLoader(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Loads values from each supported format", func(t *testing.T) {
+		loader := New(WithDir(setupConfigDir(t)))
+
+		result, err := Load[struct {
+			JSONPort int    `file:"config.json:server.port"`
+			YAMLPort int    `file:"config.yaml:server.port"`
+			TOMLPort int    `file:"config.toml:server.port"`
+			INIPort  int    `file:"config.ini:server.port"`
+			ININame  string `file:"config.ini:name"`
+			EnvHost  string `file:".env:DB_HOST"`
+			EnvPort  int    `file:".env:DB_PORT"`
+			EnvName  string `file:".env:DB_NAME"`
+		}](ctx, loader)
+
+		require.NoError(t, err)
+		assert.Equal(t, 8080, result.JSONPort)
+		assert.Equal(t, 8081, result.YAMLPort)
+		assert.Equal(t, 8082, result.TOMLPort)
+		assert.Equal(t, 8083, result.INIPort)
+		assert.Equal(t, "app", result.ININame)
+		assert.Equal(t, "env-host", result.EnvHost)
+		assert.Equal(t, 8084, result.EnvPort)
+		assert.Equal(t, "app db", result.EnvName)
+	})
+
+	t.Run("Decodes subtrees into nested structs and slices", func(t *testing.T) {
+		loader := New(WithDir(setupConfigDir(t)))
+
+		result, err := Load[struct {
+			JSONServer   serverConfig   `file:"config.json:server"`
+			YAMLServer   serverConfig   `file:"config.yaml:server"`
+			TOMLServer   serverConfig   `file:"config.toml:server"`
+			Replicas     []serverConfig `file:"config.json:replicas"`
+			TOMLReplicas []serverConfig `file:"config.toml:replicas"`
+			Tags         []string       `file:"config.yaml:tags"`
+		}](ctx, loader)
+
+		require.NoError(t, err)
+		assert.Equal(t, serverConfig{Host: "json-host", Port: 8080}, result.JSONServer)
+		assert.Equal(t, serverConfig{Host: "yaml-host", Port: 8081}, result.YAMLServer)
+		assert.Equal(t, serverConfig{Host: "toml-host", Port: 8082}, result.TOMLServer)
+		assert.Equal(t, []serverConfig{{Host: "r1"}, {Host: "r2"}}, result.Replicas)
+		assert.Equal(t, []serverConfig{{Host: "t1"}}, result.TOMLReplicas)
+		assert.Equal(t, []string{"a", "b"}, result.Tags)
+	})
+
+	t.Run("Decodes subtrees with the format's struct tags", func(t *testing.T) {
+		dir := setupConfigDir(t)
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "limits.yaml"),
+			[]byte("limits:\n  max_users: 5\n  codes:\n    404: missing\n"), 0o600))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "limits.toml"),
+			[]byte("[limits]\nmax_users = 6\nregions = [\"eu\", \"us\"]\n"), 0o600))
+
+		type limits struct {
+			MaxUsers int            `yaml:"max_users" toml:"max_users"`
+			Codes    map[int]string `yaml:"codes"`
+		}
+
+		result, err := Load[struct {
+			YAML    limits   `file:"limits.yaml:limits"`
+			TOML    limits   `file:"limits.toml:limits"`
+			Code    string   `file:"limits.yaml:limits.codes.404"`
+			Regions []string `file:"limits.toml:limits.regions"`
+		}](ctx, New(WithDir(dir)))
+
+		require.NoError(t, err)
+		assert.Equal(t, limits{MaxUsers: 5, Codes: map[int]string{404: "missing"}}, result.YAML)
+		assert.Equal(t, limits{MaxUsers: 6}, result.TOML)
+		assert.Equal(t, "missing", result.Code)
+		assert.Equal(t, []string{"eu", "us"}, result.Regions)
+	})
+
+	t.Run("Only splits the key after the extension", func(t *testing.T) {
+		fsys := fstest.MapFS{
+			"C:/cfg.yaml": {Data: []byte("host: drive-host\n")},
+		}
+
+		result, err := Load[struct {
+			Whole map[string]string `file:"C:/cfg.yaml"`
+			Host  string            `file:"C:/cfg.yaml:host"`
+		}](ctx, New(WithFS(fsys)))
+
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{"host": "drive-host"}, result.Whole)
+		assert.Equal(t, "drive-host", result.Host)
+	})
+
+	t.Run("Indexes into arrays", func(t *testing.T) {
+		loader := New(WithDir(setupConfigDir(t)))
+
+		result, err := Load[struct {
+			Host string `file:"config.json:replicas.1.host"`
+		}](ctx, loader)
+
+		require.NoError(t, err)
+		assert.Equal(t, "r2", result.Host)
+	})
+
+	t.Run("Handles optional files and keys", func(t *testing.T) {
+		loader := New(WithDir(setupConfigDir(t)))
+
+		result, err := Load[struct {
+			File string `file:"missing.yaml:server.host?"`
+			Key  string `file:"config.yaml:server.missing?"`
+		}](ctx, loader)
+
+		require.NoError(t, err)
+		assert.Empty(t, result.File)
+		assert.Empty(t, result.Key)
+	})
+
+	t.Run("Errors on missing required files and keys", func(t *testing.T) {
+		loader := New(WithDir(setupConfigDir(t)))
+
+		_, err := Load[struct {
+			Value string `file:"missing.yaml:server.host"`
+		}](ctx, loader)
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "missing.yaml")
+
+		_, err = Load[struct {
+			Value string `file:"config.yaml:server.missing"`
+		}](ctx, loader)
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "server.missing")
+	})
+
+	t.Run("Errors on unsupported extensions", func(t *testing.T) {
+		dir := setupConfigDir(t)
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "config.xml"), []byte("<xml/>"), 0o600))
+
+		_, err := Load[struct {
+			Value string `file:"config.xml:value"`
+		}](ctx, New(WithDir(dir)))
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "unsupported file extension")
+	})
+
+	t.Run("Handles references to other fields", func(t *testing.T) {
+		dir := setupConfigDir(t)
+		t.Setenv("CONFIG_PATH", filepath.Join(dir, "config.yaml"))
+
+		result, err := Load[struct {
+			ConfigPath string `env:"CONFIG_PATH"`
+			Host       string `file:"@ConfigPath||:server.host"`
+		}](ctx, env.New(), New())
+
+		require.NoError(t, err)
+		assert.Equal(t, "yaml-host", result.Host)
+	})
+
+	t.Run("Parses each document again only when it changes", func(t *testing.T) {
+		dir := setupConfigDir(t)
+		loader := New(WithDir(dir))
+
+		type Config struct {
+			Host string `file:"config.yaml:server.host"`
+		}
+
+		first, err := Load[Config](ctx, loader)
+		require.NoError(t, err)
+		assert.Equal(t, "yaml-host", first.Host)
+
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "config.yaml"), []byte("server:\n  host: changed-host\n"), 0o600))
+
+		second, err := Load[Config](ctx, loader)
+		require.NoError(t, err)
+		assert.Equal(t, "changed-host", second.Host)
+	})
+
+	t.Run("Reads from a file system", func(t *testing.T) {
+		fsys := fstest.MapFS{
+			"configs/app.json": {Data: []byte(`{"name": "from-fs"}`)},
+		}
+
+		result, err := Load[struct {
+			Name string `file:"configs/app.json:name"`
+		}](ctx, New(WithFS(fsys)))
+
+		require.NoError(t, err)
+		assert.Equal(t, "from-fs", result.Name)
+	})
+}
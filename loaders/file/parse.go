@@ -0,0 +1,266 @@
+package file
+
+import (
+	"bufio"
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/Gardego5/gocfg/utils"
+	"gopkg.in/yaml.v3"
+)
+
+// format returns the format of a file based on its name: json, yaml, toml,
+// ini or dotenv, or its extension if it is unsupported
+func format(filePath string) string {
+	base := filepath.Base(filePath)
+	if base == ".env" || strings.HasPrefix(base, ".env.") {
+		return "dotenv"
+	}
+
+	switch ext := strings.ToLower(filepath.Ext(base)); ext {
+	case ".json":
+		return "json"
+	case ".yaml", ".yml":
+		return "yaml"
+	case ".toml":
+		return "toml"
+	case ".ini":
+		return "ini"
+	case ".env":
+		return "dotenv"
+	default:
+		return ext
+	}
+}
+
+// parse decodes a file into plain Go values based on its extension
+func parse(filePath string, data []byte) (any, error) {
+	switch f := format(filePath); f {
+	case "json":
+		var root any
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+		if err := decoder.Decode(&root); err != nil {
+			return nil, err
+		}
+		return root, nil
+
+	case "yaml":
+		var root any
+		if err := yaml.Unmarshal(data, &root); err != nil {
+			return nil, err
+		}
+		return root, nil
+
+	case "toml":
+		var root map[string]any
+		if err := toml.Unmarshal(data, &root); err != nil {
+			return nil, err
+		}
+		return root, nil
+
+	case "ini":
+		return parseINI(data)
+
+	case "dotenv":
+		return parseDotenv(data)
+
+	default:
+		return nil, fmt.Errorf("unsupported file extension %q", f)
+	}
+}
+
+// normalizeYAML returns a copy of a YAML node with the map[any]any YAML
+// decodes mappings with non-string keys into, such as numbers, converted to
+// map[string]any
+func normalizeYAML(node any) any {
+	switch n := node.(type) {
+	case map[any]any:
+		normalized := make(map[string]any, len(n))
+		for key, value := range n {
+			normalized[fmt.Sprint(key)] = normalizeYAML(value)
+		}
+		return normalized
+
+	case map[string]any:
+		normalized := make(map[string]any, len(n))
+		for key, value := range n {
+			normalized[key] = normalizeYAML(value)
+		}
+		return normalized
+
+	case []any:
+		normalized := make([]any, len(n))
+		for i, value := range n {
+			normalized[i] = normalizeYAML(value)
+		}
+		return normalized
+
+	default:
+		return node
+	}
+}
+
+// decode sets a field from a node of a parsed document. Objects and arrays
+// decoded into struct, map, slice and array fields are re-encoded in the
+// document's format and decoded by its decoder, so the field's yaml or toml
+// struct tags apply.
+func decode(format string, node any, value reflect.Value) error {
+	if isComposite(node) && decodesComposite(value) {
+		switch format {
+		case "yaml":
+			data, err := yaml.Marshal(node)
+			if err != nil {
+				return fmt.Errorf("failed to encode YAML value: %w", err)
+			}
+			return yaml.Unmarshal(data, value.Addr().Interface())
+
+		case "toml":
+			// A TOML document is a table, so wrap the node in one
+			var data bytes.Buffer
+			if err := toml.NewEncoder(&data).Encode(map[string]any{"value": node}); err != nil {
+				return fmt.Errorf("failed to encode TOML value: %w", err)
+			}
+
+			wrapper := reflect.New(reflect.StructOf([]reflect.StructField{{
+				Name: "Value", Type: value.Type(), Tag: `toml:"value"`,
+			}}))
+			if _, err := toml.Decode(data.String(), wrapper.Interface()); err != nil {
+				return err
+			}
+			value.Set(wrapper.Elem().Field(0))
+			return nil
+		}
+	}
+
+	if format == "yaml" {
+		node = normalizeYAML(node)
+	}
+	return utils.SetFieldJSONValue(value, node)
+}
+
+// isComposite reports whether a node is an object or an array
+func isComposite(node any) bool {
+	switch node.(type) {
+	case map[string]any, map[any]any, []any, []map[string]any:
+		return true
+	}
+	return false
+}
+
+// decodesComposite reports whether a field is decoded from objects and
+// arrays as a whole, rather than from their JSON text
+func decodesComposite(value reflect.Value) bool {
+	switch value.Addr().Interface().(type) {
+	case encoding.TextUnmarshaler, encoding.BinaryUnmarshaler, json.Unmarshaler:
+		return false
+	}
+
+	switch value.Kind() {
+	case reflect.Struct, reflect.Map, reflect.Slice, reflect.Array:
+		return true
+	}
+	return false
+}
+
+// parseINI decodes an INI file. Keys before the first section are top-level
+// values and each [section] becomes an object; dotted section names nest.
+func parseINI(data []byte) (map[string]any, error) {
+	root := make(map[string]any)
+	section := root
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == ';' || line[0] == '#' {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") {
+			if !strings.HasSuffix(line, "]") {
+				return nil, fmt.Errorf("line %d: unterminated section header", lineNumber)
+			}
+
+			section = root
+			for _, name := range strings.Split(strings.TrimSpace(line[1:len(line)-1]), ".") {
+				child, ok := section[name].(map[string]any)
+				if !ok {
+					child = make(map[string]any)
+					section[name] = child
+				}
+				section = child
+			}
+			continue
+		}
+
+		key, val, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected key = value", lineNumber)
+		}
+		section[strings.TrimSpace(key)] = unquote(strings.TrimSpace(val))
+	}
+
+	return root, scanner.Err()
+}
+
+// parseDotenv decodes a dotenv file of KEY=VALUE lines, allowing comments,
+// an "export " prefix and quoted values.
+func parseDotenv(data []byte) (map[string]any, error) {
+	root := make(map[string]any)
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+
+		line = strings.TrimPrefix(line, "export ")
+
+		key, val, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected KEY=VALUE", lineNumber)
+		}
+
+		val = strings.TrimSpace(val)
+		if len(val) > 0 && val[0] != '"' && val[0] != '\'' {
+			// Strip trailing comments from unquoted values
+			if idx := strings.Index(val, " #"); idx >= 0 {
+				val = strings.TrimSpace(val[:idx])
+			}
+		}
+
+		root[strings.TrimSpace(key)] = unquote(val)
+	}
+
+	return root, scanner.Err()
+}
+
+// unquote removes matching quotes around a value. Double quoted values have
+// their escape sequences interpreted.
+func unquote(val string) string {
+	if len(val) < 2 {
+		return val
+	}
+
+	switch {
+	case val[0] == '"' && val[len(val)-1] == '"':
+		if unquoted, err := strconv.Unquote(val); err == nil {
+			return unquoted
+		}
+		return val[1 : len(val)-1]
+
+	case val[0] == '\'' && val[len(val)-1] == '\'':
+		return val[1 : len(val)-1]
+
+	default:
+		return val
+	}
+}
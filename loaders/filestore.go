@@ -0,0 +1,137 @@
+package loaders
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+type fileStore struct {
+	path string
+	aead cipher.AEAD
+
+	mu      sync.Mutex
+	entries map[string]CacheEntry // Contents of the file, once read
+}
+
+// NewFileStore returns a CacheStore keeping its entries in the file at path,
+// encrypted with AES-GCM using key, which must be 16, 24 or 32 bytes long.
+// The file is created with permissions 0600 and replaced atomically on every
+// Put, which writes all the entries put together at once. It panics if the key
+// has an invalid length.
+func NewFileStore(path string, key []byte) CacheStore {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		panic(err)
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		panic(err)
+	}
+
+	return &fileStore{path: path, aead: aead}
+}
+
+func (s *fileStore) Get(key string) (CacheEntry, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.read(); err != nil {
+		return CacheEntry{}, false, err
+	}
+
+	entry, exists := s.entries[key]
+	return entry, exists, nil
+}
+
+func (s *fileStore) Put(entries map[string]CacheEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.read(); err != nil {
+		s.entries = make(map[string]CacheEntry) // Replace the unreadable file
+	}
+	for key, entry := range entries {
+		s.entries[key] = entry
+	}
+
+	return s.write()
+}
+
+// read reads the entries from the file, if it wasn't read yet
+func (s *fileStore) read() error {
+	if s.entries != nil {
+		return nil
+	}
+
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		s.entries = make(map[string]CacheEntry)
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to read cache file %s: %w", s.path, err)
+	}
+
+	nonceSize := s.aead.NonceSize()
+	if len(data) < nonceSize {
+		return fmt.Errorf("failed to decrypt cache file %s: file too short", s.path)
+	}
+
+	plaintext, err := s.aead.Open(nil, data[:nonceSize], data[nonceSize:], nil)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt cache file %s: %w", s.path, err)
+	}
+
+	var entries map[string]CacheEntry
+	if err := json.Unmarshal(plaintext, &entries); err != nil {
+		return fmt.Errorf("failed to decode cache file %s: %w", s.path, err)
+	}
+	if entries == nil {
+		entries = make(map[string]CacheEntry)
+	}
+
+	s.entries = entries
+	return nil
+}
+
+// write encrypts the entries to a temporary file, then renames it over the
+// file so readers never see a partial write
+func (s *fileStore) write() error {
+	plaintext, err := json.Marshal(s.entries)
+	if err != nil {
+		return fmt.Errorf("failed to encode cache file %s: %w", s.path, err)
+	}
+
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return fmt.Errorf("failed to encrypt cache file %s: %w", s.path, err)
+	}
+	data := s.aead.Seal(nonce, nonce, plaintext, nil)
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to write cache file %s: %w", s.path, err)
+	}
+	defer os.Remove(tmp.Name()) // Fails harmlessly once renamed
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write cache file %s: %w", s.path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write cache file %s: %w", s.path, err)
+	}
+
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("failed to write cache file %s: %w", s.path, err)
+	}
+	return nil
+}
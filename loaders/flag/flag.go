@@ -0,0 +1,228 @@
+package flag
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/Gardego5/gocfg"
+	"github.com/Gardego5/gocfg/utils"
+)
+
+// Option configures the flag loader
+type Option func(*loader)
+
+// WithArgs parses args instead of os.Args[1:]
+func WithArgs(args []string) Option {
+	return func(l *loader) { l.args = args }
+}
+
+// WithFlagSet registers flags on fs instead of a new flag set named after the
+// program. Its error handling setting decides how parse errors are reported.
+func WithFlagSet(fs *flag.FlagSet) Option {
+	return func(l *loader) { l.flagSet = fs }
+}
+
+// FlagLoader loads configuration from command-line flags declared by the
+// flag tags of C's fields
+func New[C any](opts ...Option) gocfg.Loader {
+	l := &loader{
+		args:    os.Args[1:],
+		flagSet: flag.NewFlagSet(os.Args[0], flag.ContinueOnError),
+		flags:   make(map[string]*value),
+	}
+
+	for _, opt := range opts {
+		opt(l)
+	}
+
+	l.register(reflect.TypeFor[C]())
+
+	return l
+}
+
+type loader struct {
+	args    []string
+	flagSet *flag.FlagSet
+	flags   map[string]*value // Keyed by long name
+	order   []*value
+
+	parseOnce sync.Once
+	parseErr  error
+}
+
+// value is a flag.Value recording the text of an explicitly passed flag
+type value struct {
+	spec
+	isBool bool
+	text   string
+	set    bool
+}
+
+func (v *value) String() string {
+	if v == nil || !v.set {
+		return ""
+	}
+	return v.text
+}
+
+func (v *value) Set(text string) error {
+	v.text, v.set = text, true
+	return nil
+}
+
+func (v *value) IsBoolFlag() bool { return v.isBool }
+
+// spec is a flag declared by a tag
+type spec struct {
+	name, short  string
+	defaultValue string
+	hasDefault   bool
+	usage        string
+}
+
+// parseSpec parses a tag of the form "name[,short][=default][;usage]". Flags
+// are declared before any field is loaded, so tags can't reference fields.
+func parseSpec(tag string) (s spec, err error) {
+	if utils.IndexTag(tag, "@") >= 0 {
+		return s, utils.InvalidTagError("invalid flag tag %q: flag tags can't reference fields", tag)
+	}
+
+	tag, s.usage, _ = utils.CutTag(tag, ";")
+	s.usage = utils.UnescapeTag(strings.TrimSpace(s.usage))
+
+	names, defaultValue, hasDefault := utils.CutTag(tag, "=")
+	s.defaultValue, s.hasDefault = utils.UnescapeTag(defaultValue), hasDefault
+
+	names, short, _ := utils.CutTag(names, ",")
+	s.name = utils.UnescapeTag(strings.TrimSpace(names))
+	s.short = utils.UnescapeTag(strings.TrimSpace(short))
+	if s.name == "" {
+		return s, utils.InvalidTagError("invalid flag tag %q: expected name[,short][=default][;usage]", tag)
+	}
+
+	return s, nil
+}
+
+func (*loader) GocfgLoaderName() string { return "flag" }
+
+// GocfgDefault gives the default of a flag tag, so it applies once no loader
+// has a value for the field
+func (*loader) GocfgDefault(_ reflect.StructField, tag string) (string, bool) {
+	tag, _, _ = utils.CutTag(tag, ";")
+	_, defaultTag, ok := utils.CutTag(tag, "=")
+	return defaultTag, ok
+}
+
+// register declares a flag for every field of t with a flag tag, including
+// fields of nested structs, and replaces the flag set's usage with help
+// generated from those tags
+func (l *loader) register(t reflect.Type) {
+	l.registerFields(t)
+	l.flagSet.Usage = l.usage
+}
+
+func (l *loader) registerFields(t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() && !field.Anonymous {
+			continue
+		}
+
+		tag := field.Tag.Get(l.GocfgLoaderName())
+		if tag == "" {
+			if field.Type.Kind() == reflect.Struct {
+				l.registerFields(field.Type)
+			}
+			continue
+		}
+
+		s, err := parseSpec(tag)
+		if err != nil {
+			panic(fmt.Sprintf("field %s: %s", field.Name, err))
+		}
+
+		v := &value{spec: s, isBool: field.Type.Kind() == reflect.Bool}
+		l.flagSet.Var(v, s.name, s.usage)
+		if s.short != "" {
+			l.flagSet.Var(v, s.short, s.usage)
+		}
+
+		l.flags[s.name] = v
+		l.order = append(l.order, v)
+	}
+}
+
+// usage prints help listing each flag once with its short name and default
+func (l *loader) usage() {
+	out := l.flagSet.Output()
+	if name := l.flagSet.Name(); name != "" {
+		fmt.Fprintf(out, "Usage of %s:\n", name)
+	} else {
+		fmt.Fprintf(out, "Usage:\n")
+	}
+
+	for _, v := range l.order {
+		line := "  -" + v.name
+		if v.short != "" {
+			line += ", -" + v.short
+		}
+		if !v.isBool {
+			line += " value"
+		}
+
+		line += "\n    \t" + v.usage
+		if v.hasDefault {
+			line += fmt.Sprintf(" (default %q)", v.defaultValue)
+		}
+
+		fmt.Fprintln(out, line)
+	}
+}
+
+// Load implements the Loader interface for command-line flags
+// Tag formats supported:
+// - "port" - Flag -port
+// - "port,p" - Flag -port with the short name -p
+// - "port=8080" - Flag with a default value
+// - "port,p=8080;HTTP listen port" - Flag with a usage message for --help
+//
+// Fields are only set when their flag was passed. Otherwise the field's next
+// loader is tried, so flags can override other sources such as environment
+// variables when the flag loader is passed first. The default in the tag
+// applies once no loader has a value, like a default tag, which takes
+// precedence over it.
+func (l *loader) Load(
+	ctx context.Context,
+	field reflect.StructField, fieldValue reflect.Value,
+	resolvedTag string,
+) error {
+	l.parseOnce.Do(func() {
+		if err := l.flagSet.Parse(l.args); err != nil {
+			l.parseErr = fmt.Errorf("failed to parse flags: %w", err)
+		}
+	})
+	if l.parseErr != nil {
+		return l.parseErr
+	}
+
+	// Flags were declared from the tags as written, so look them up the same way
+	s, err := parseSpec(field.Tag.Get(l.GocfgLoaderName()))
+	if err != nil {
+		return err
+	}
+
+	v, exists := l.flags[s.name]
+	if !exists {
+		return fmt.Errorf("flag -%s is not declared by the configuration type", s.name)
+	}
+
+	if !v.set {
+		return fmt.Errorf("%w: flag -%s not passed", utils.ErrNotFound, s.name)
+	}
+	return utils.SetFieldValue(fieldValue, v.text)
+}
@@ -0,0 +1,148 @@
+package flag_test
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"testing"
+
+	. "github.com/Gardego5/gocfg"
+	"github.com/Gardego5/gocfg/loaders/env"
+	. "github.com/Gardego5/gocfg/loaders/flag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type serverConfig struct {
+	Port    int    `flag:"port,p=8080;HTTP listen port"`
+	Host    string `flag:"host;Interface to listen on" env:"HOST=localhost"`
+	Verbose bool   `flag:"verbose,v;Enable verbose logging"`
+}
+
+func TestFlagLoader(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Sets fields from passed flags", func(t *testing.T) {
+		result, err := Load[serverConfig](ctx, New[serverConfig](
+			WithArgs([]string{"-port", "9090", "-host", "0.0.0.0", "-verbose"}),
+		))
+
+		require.NoError(t, err)
+		assert.Equal(t, 9090, result.Port)
+		assert.Equal(t, "0.0.0.0", result.Host)
+		assert.True(t, result.Verbose)
+	})
+
+	t.Run("Accepts short names", func(t *testing.T) {
+		result, err := Load[serverConfig](ctx, New[serverConfig](
+			WithArgs([]string{"-p", "9090", "-v"}),
+		))
+
+		require.NoError(t, err)
+		assert.Equal(t, 9090, result.Port)
+		assert.True(t, result.Verbose)
+	})
+
+	t.Run("Uses the tag's default for flags that were not passed", func(t *testing.T) {
+		result, err := Load[serverConfig](ctx, New[serverConfig](WithArgs(nil)))
+
+		require.NoError(t, err)
+		assert.Equal(t, 8080, result.Port)
+		assert.False(t, result.Verbose)
+	})
+
+	t.Run("Falls back to later loaders when a flag was not passed", func(t *testing.T) {
+		t.Setenv("HOST", "from-env")
+
+		result, err := Load[serverConfig](ctx, New[serverConfig](WithArgs(nil)), env.New())
+
+		require.NoError(t, err)
+		assert.Equal(t, "from-env", result.Host)
+	})
+
+	t.Run("Prefers later loaders to defaults when a flag was not passed", func(t *testing.T) {
+		type config struct {
+			Port int `flag:"port=8080" env:"MYAPP_PORT"`
+		}
+
+		result, err := Load[config](ctx, New[config](WithArgs(nil)), env.New())
+		require.NoError(t, err)
+		assert.Equal(t, 8080, result.Port)
+
+		t.Setenv("MYAPP_PORT", "9090")
+		result, err = Load[config](ctx, New[config](WithArgs(nil)), env.New())
+		require.NoError(t, err)
+		assert.Equal(t, 9090, result.Port)
+
+		result, err = Load[config](ctx, New[config](WithArgs([]string{"-port", "7070"})), env.New())
+		require.NoError(t, err)
+		assert.Equal(t, 7070, result.Port)
+	})
+
+	t.Run("Overrides later loaders when a flag was passed", func(t *testing.T) {
+		t.Setenv("HOST", "from-env")
+
+		result, err := Load[serverConfig](ctx, New[serverConfig](
+			WithArgs([]string{"-host", "from-flag"}),
+		), env.New())
+
+		require.NoError(t, err)
+		assert.Equal(t, "from-flag", result.Host)
+	})
+
+	t.Run("Generates help from the struct", func(t *testing.T) {
+		var output bytes.Buffer
+		fs := flag.NewFlagSet("server", flag.ContinueOnError)
+		fs.SetOutput(&output)
+
+		_, err := Load[serverConfig](ctx, New[serverConfig](
+			WithFlagSet(fs),
+			WithArgs([]string{"-help"}),
+		))
+
+		require.ErrorIs(t, err, flag.ErrHelp)
+		assert.Equal(t, `Usage of server:
+  -port, -p value
+    	HTTP listen port (default "8080")
+  -host value
+    	Interface to listen on
+  -verbose, -v
+    	Enable verbose logging
+`, output.String())
+	})
+
+	t.Run("Errors on invalid flag values", func(t *testing.T) {
+		fs := flag.NewFlagSet("server", flag.ContinueOnError)
+		fs.SetOutput(&bytes.Buffer{})
+
+		_, err := Load[serverConfig](ctx, New[serverConfig](
+			WithFlagSet(fs),
+			WithArgs([]string{"-port", "not-a-number"}),
+		))
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "Port")
+	})
+
+	t.Run("Rejects tags referencing fields", func(t *testing.T) {
+		type config struct {
+			Env  string `flag:"env"`
+			Port int    `flag:"@Env-port"`
+		}
+
+		assert.Panics(t, func() { New[config](WithArgs(nil)) })
+	})
+
+	t.Run("Errors on undeclared flags", func(t *testing.T) {
+		fs := flag.NewFlagSet("server", flag.ContinueOnError)
+		fs.SetOutput(&bytes.Buffer{})
+
+		_, err := Load[serverConfig](ctx, New[serverConfig](
+			WithFlagSet(fs),
+			WithArgs([]string{"-bogus"}),
+		))
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "bogus")
+	})
+}
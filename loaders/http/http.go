@@ -0,0 +1,444 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Gardego5/gocfg"
+	"github.com/Gardego5/gocfg/utils"
+)
+
+const (
+	// defaultTimeout bounds each request unless WithHTTPClient says otherwise
+	defaultTimeout = 10 * time.Second
+
+	// defaultMaxBytes bounds the size of responses unless WithMaxBytes says
+	// otherwise
+	defaultMaxBytes = 1 << 20
+
+	// maxDocuments bounds the documents kept for revalidation
+	maxDocuments = 100
+)
+
+// Option configures the HTTP loader
+type Option func(*loader)
+
+// WithHTTPClient sets the HTTP client used to fetch documents. The default
+// client times out after 10s.
+func WithHTTPClient(client *http.Client) Option {
+	return func(l *loader) { l.httpClient = client }
+}
+
+// WithHeader sends a header with every request, in addition to the headers
+// in tags
+func WithHeader(name, value string) Option {
+	return func(l *loader) { l.headers.Add(name, value) }
+}
+
+// WithMaxBytes fails loads whose response is larger than n bytes, 1 MiB by
+// default
+func WithMaxBytes(n int64) Option {
+	return func(l *loader) { l.maxBytes = n }
+}
+
+// HTTPLoader loads configuration from HTTP endpoints serving JSON or plain
+// text, such as config services or instance metadata endpoints
+func New(opts ...Option) gocfg.Loader {
+	l := &loader{
+		httpClient: &http.Client{Timeout: defaultTimeout},
+		headers:    make(http.Header),
+		maxBytes:   defaultMaxBytes,
+		documents:  make(map[string]*document),
+	}
+
+	for _, opt := range opts {
+		opt(l)
+	}
+
+	return l
+}
+
+type loader struct {
+	httpClient *http.Client
+	headers    http.Header
+	maxBytes   int64
+
+	mu        sync.Mutex
+	documents map[string]*document // Validated documents by request
+	kept      []string             // Keys of documents, oldest first
+}
+
+// document is a response body, with the validators to revalidate it
+type document struct {
+	body         []byte
+	etag         string
+	lastModified string
+
+	once    sync.Once
+	decoded any
+	err     error
+}
+
+// json returns the body decoded as JSON, decoding it the first time
+func (d *document) json() (any, error) {
+	d.once.Do(func() {
+		decoder := json.NewDecoder(bytes.NewReader(d.body))
+		decoder.UseNumber()
+		d.err = decoder.Decode(&d.decoded)
+	})
+	return d.decoded, d.err
+}
+
+func (*loader) GocfgLoaderName() string { return "http" }
+func (*loader) GocfgEscapedTags() bool  { return true }
+
+// Load implements the Loader interface for HTTP endpoints
+// Tag formats supported:
+// - "https://cfg.internal/app.json#/db/host" - Get a value from a JSON
+// document with a JSON pointer
+// - "https://cfg.internal/app.json#" - Decode the whole JSON document
+// - "http://169.254.169.254/latest/meta-data/instance-id" - Use the whole
+// response as text
+// - "https://cfg.internal/app.json#/db/host?" - Optional document or value
+// - "https://cfg.internal/app.json#/db/host; Authorization: Bearer @Token" -
+// Send headers, separated by ;, built from other fields
+//
+// Without a pointer, struct, map and slice fields decode the response as
+// JSON, and other fields, including encoding.TextUnmarshalers, receive it as
+// text with surrounding whitespace trimmed. A 404 response or a pointer
+// matching nothing is a missing value, other failures are errors.
+//
+// Documents carrying an ETag or Last-Modified header are kept by the loader
+// and revalidated on later loads, so an unchanged document is not downloaded
+// again. Up to 100 documents are kept, forgetting the oldest first. Each
+// document is fetched once per batch, however many fields read it.
+func (l *loader) Load(
+	ctx context.Context,
+	field reflect.StructField, value reflect.Value,
+	resolvedTag string,
+) error {
+	ref, err := parseTag(resolvedTag)
+	if err != nil {
+		return err
+	}
+
+	doc, err := l.fetch(ctx, ref)
+	return ref.set(value, doc, err)
+}
+
+// LoadBatch implements gocfg.BatchLoader, fetching each document once for
+// all the fields reading it
+func (l *loader) LoadBatch(ctx context.Context, requests []gocfg.FieldRequest) []error {
+	errs := make([]error, len(requests))
+
+	type fetched struct {
+		doc *document
+		err error
+	}
+	documents := make(map[string]fetched)
+
+	for i, request := range requests {
+		ref, err := parseTag(request.Tag)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+
+		key := ref.key()
+		f, exists := documents[key]
+		if !exists {
+			f.doc, f.err = l.fetch(ctx, ref)
+			documents[key] = f
+		}
+
+		errs[i] = ref.set(request.Value, f.doc, f.err)
+	}
+
+	return errs
+}
+
+// endpointRef is a parsed tag
+type endpointRef struct {
+	url      string
+	pointer  string
+	hasPtr   bool
+	headers  http.Header
+	optional bool
+}
+
+// parseTag parses the tag of a field
+func parseTag(resolvedTag string) (ref endpointRef, err error) {
+	// Handle special case - fully resolved reference or concatenation
+	if strings.HasPrefix(resolvedTag, "@") || strings.Contains(resolvedTag, "||") {
+		// At this point the tag should be resolved already
+		return ref, fmt.Errorf("unexpected unresolved tag: %s", resolvedTag)
+	}
+
+	// Check if value is optional
+	tag, isOptional := utils.CutTagSuffix(strings.TrimSpace(resolvedTag), "?")
+	ref.optional = isOptional
+
+	parts := utils.SplitTag(tag, ";")
+
+	// Check for JSON pointer specification
+	endpoint, pointer, hasPtr := utils.CutTag(parts[0], "#")
+	ref.url = utils.UnescapeTag(strings.TrimSpace(endpoint))
+	ref.hasPtr = hasPtr
+
+	parsed, err := url.Parse(ref.url)
+	if err != nil {
+		return ref, utils.InvalidTagError("invalid tag %q: %w", resolvedTag, err)
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" || parsed.Host == "" {
+		return ref, utils.InvalidTagError("invalid tag %q: expected an http or https URL", resolvedTag)
+	}
+
+	if hasPtr {
+		pointer = utils.UnescapeTag(strings.TrimSpace(pointer))
+		if ref.pointer, err = url.PathUnescape(pointer); err != nil {
+			return ref, utils.InvalidTagError("invalid JSON pointer %q: %w", pointer, err)
+		}
+		if ref.pointer != "" && !strings.HasPrefix(ref.pointer, "/") {
+			return ref, utils.InvalidTagError("invalid JSON pointer %q: expected it to start with /", pointer)
+		}
+	}
+
+	ref.headers = make(http.Header)
+	for _, header := range parts[1:] {
+		if strings.TrimSpace(header) == "" {
+			continue
+		}
+
+		name, value, found := utils.CutTag(header, ":")
+		name = utils.UnescapeTag(strings.TrimSpace(name))
+		if !found || name == "" {
+			return ref, utils.InvalidTagError("invalid tag %q: expected headers as name: value", resolvedTag)
+		}
+		ref.headers.Add(name, utils.UnescapeTag(strings.TrimSpace(value)))
+	}
+
+	return ref, nil
+}
+
+// key identifies the request fetching the referenced document. Headers are
+// hashed, so keys don't hold the credentials they often carry.
+func (ref endpointRef) key() string {
+	names := make([]string, 0, len(ref.headers))
+	for name := range ref.headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	headers := sha256.New()
+	for _, name := range names {
+		for _, value := range ref.headers[name] {
+			fmt.Fprintf(headers, "%s: %s\n", name, value)
+		}
+	}
+	return ref.url + "\n" + hex.EncodeToString(headers.Sum(nil))
+}
+
+// set sets a field from the referenced document, or reports the error
+// fetching it
+func (ref endpointRef) set(value reflect.Value, doc *document, err error) error {
+	if err != nil {
+		return err
+	}
+	if doc == nil {
+		return utils.NotFoundError(ref.optional, "%s not found", ref.url)
+	}
+
+	if !ref.hasPtr {
+		if _, ok := value.Addr().Interface().(*[]byte); ok {
+			value.SetBytes(bytes.Clone(doc.body)) // Documents are shared
+			return nil
+		}
+
+		_, isText := value.Addr().Interface().(encoding.TextUnmarshaler)
+		switch value.Kind() {
+		case reflect.Struct, reflect.Map, reflect.Slice, reflect.Array:
+			if !isText {
+				break
+			}
+			fallthrough
+		default:
+			return utils.SetFieldValue(value, strings.TrimSpace(string(doc.body)))
+		}
+	}
+
+	decoded, err := doc.json()
+	if err != nil {
+		return fmt.Errorf("failed to decode %s as JSON: %w", ref.url, err)
+	}
+
+	jsonValue, exists := resolvePointer(decoded, ref.pointer)
+	if !exists {
+		return utils.NotFoundError(ref.optional, "%s not found in %s", ref.pointer, ref.url)
+	}
+
+	return utils.SetFieldJSONValue(value, jsonValue)
+}
+
+// resolvePointer returns the value a JSON pointer (RFC 6901) references in a
+// document, or false if there is none
+func resolvePointer(document any, pointer string) (any, bool) {
+	if pointer == "" {
+		return document, true
+	}
+
+	current := document
+	for _, token := range strings.Split(pointer[1:], "/") {
+		token = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+
+		switch node := current.(type) {
+		case map[string]any:
+			next, exists := node[token]
+			if !exists {
+				return nil, false
+			}
+			current = next
+
+		case []any:
+			if token == "" || (len(token) > 1 && token[0] == '0') {
+				return nil, false
+			}
+			index, err := strconv.Atoi(token)
+			if err != nil || index < 0 || index >= len(node) {
+				return nil, false
+			}
+			current = node[index]
+
+		default:
+			return nil, false
+		}
+	}
+
+	return current, true
+}
+
+// fetch returns the referenced document, or nil if the endpoint returned 404.
+// Documents the loader kept are revalidated rather than downloaded again.
+func (l *loader) fetch(ctx context.Context, ref endpointRef) (*document, error) {
+	key := ref.key()
+
+	l.mu.Lock()
+	cached := l.documents[key]
+	l.mu.Unlock()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, ref.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range l.headers {
+		req.Header[name] = append([]string(nil), values...)
+	}
+	for name, values := range ref.headers {
+		req.Header[name] = append([]string(nil), values...)
+	}
+	if cached != nil {
+		if cached.etag != "" {
+			req.Header.Set("If-None-Match", cached.etag)
+		}
+		if cached.lastModified != "" {
+			req.Header.Set("If-Modified-Since", cached.lastModified)
+		}
+	}
+
+	resp, err := l.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %w", ref.url, err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotModified && cached != nil:
+		return cached, nil
+
+	case resp.StatusCode == http.StatusNotFound:
+		l.forget(key)
+		return nil, nil
+
+	case resp.StatusCode < 200 || resp.StatusCode >= 300:
+		return nil, fmt.Errorf("failed to fetch %s: server returned %s", ref.url, resp.Status)
+	}
+
+	body, err := l.read(resp)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %w", ref.url, err)
+	}
+
+	doc := &document{
+		body:         body,
+		etag:         resp.Header.Get("ETag"),
+		lastModified: resp.Header.Get("Last-Modified"),
+	}
+
+	if doc.etag != "" || doc.lastModified != "" {
+		l.keep(key, doc)
+	} else {
+		l.forget(key)
+	}
+
+	return doc, nil
+}
+
+// keep keeps a document for revalidation, forgetting the oldest one when
+// maxDocuments are kept already
+func (l *loader) keep(key string, doc *document) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, exists := l.documents[key]; !exists {
+		l.kept = append(l.kept, key)
+		if len(l.kept) > maxDocuments {
+			delete(l.documents, l.kept[0])
+			l.kept = l.kept[1:]
+		}
+	}
+	l.documents[key] = doc
+}
+
+// forget forgets the document kept for key, if any
+func (l *loader) forget(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, exists := l.documents[key]; !exists {
+		return
+	}
+	delete(l.documents, key)
+	l.kept = slices.DeleteFunc(l.kept, func(k string) bool { return k == key })
+}
+
+// read reads a response body, failing if it is larger than maxBytes
+func (l *loader) read(resp *http.Response) ([]byte, error) {
+	tooLarge := fmt.Errorf("response larger than %d bytes", l.maxBytes)
+	if resp.ContentLength > l.maxBytes {
+		return nil, tooLarge
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, l.maxBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(body)) > l.maxBytes {
+		return nil, tooLarge
+	}
+
+	return body, nil
+}
@@ -0,0 +1,324 @@
+package http_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	. "github.com/Gardego5/gocfg"
+	"github.com/Gardego5/gocfg/loaders/env"
+	. "github.com/Gardego5/gocfg/loaders/http"
+	"github.com/Gardego5/gocfg/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// MockConfigService serves config documents, revalidating them with ETags
+type MockConfigService struct {
+	Token string
+
+	mu        sync.Mutex
+	Documents map[string]string
+	Requests  map[string]int
+	Downloads map[string]int
+}
+
+func (m *MockConfigService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.Token != "" && r.Header.Get("Authorization") != "Bearer "+m.Token {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	m.Requests[r.URL.Path]++
+
+	document, exists := m.Documents[r.URL.Path]
+	if !exists {
+		http.NotFound(w, r)
+		return
+	}
+
+	etag := fmt.Sprintf(`"%d"`, len(document))
+	w.Header().Set("ETag", etag)
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	m.Downloads[r.URL.Path]++
+	_, _ = w.Write([]byte(document))
+}
+
+func (m *MockConfigService) Set(path, document string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.Documents[path] = document
+}
+
+func (m *MockConfigService) Remove(path string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.Documents, path)
+}
+
+func setupMockConfigService(t *testing.T, documents map[string]string) *MockConfigService {
+	service := &MockConfigService{
+		Documents: documents,
+		Requests:  make(map[string]int),
+		Downloads: make(map[string]int),
+	}
+	server := httptest.NewServer(service)
+	t.Cleanup(server.Close)
+	t.Setenv("CONFIG_URL", server.URL)
+	return service
+}
+
+const appDocument = `{
+	"db": {"host": "db.internal", "port": 5432, "replicas": ["r1", "r2"]},
+	"features": {"a/b": true, "m~n": "tilde"},
+	"debug": false
+}`
+
+func TestHTTPLoader(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Extracts values with JSON pointers", func(t *testing.T) {
+		setupMockConfigService(t, map[string]string{"/app.json": appDocument})
+
+		type Database struct {
+			Host string `json:"host"`
+			Port int    `json:"port"`
+		}
+
+		result, err := Load[struct {
+			URL      string   `env:"CONFIG_URL"`
+			Host     string   `http:"@URL||/app.json#/db/host"`
+			Port     int      `http:"@URL||/app.json#/db/port"`
+			Replica  string   `http:"@URL||/app.json#/db/replicas/1"`
+			Slash    bool     `http:"@URL||/app.json#/features/a~1b"`
+			Tilde    string   `http:"@URL||/app.json#/features/m~0n"`
+			Debug    bool     `http:"@URL||/app.json#/debug"`
+			Database Database `http:"@URL||/app.json#/db"`
+		}](ctx, env.New(), New())
+
+		require.NoError(t, err)
+		assert.Equal(t, "db.internal", result.Host)
+		assert.Equal(t, 5432, result.Port)
+		assert.Equal(t, "r2", result.Replica)
+		assert.True(t, result.Slash)
+		assert.Equal(t, "tilde", result.Tilde)
+		assert.False(t, result.Debug)
+		assert.Equal(t, Database{Host: "db.internal", Port: 5432}, result.Database)
+	})
+
+	t.Run("Uses responses as text without a pointer", func(t *testing.T) {
+		setupMockConfigService(t, map[string]string{
+			"/latest/meta-data/instance-id": "i-0123456789\n",
+			"/app.json":                     appDocument,
+		})
+
+		result, err := Load[struct {
+			URL        string         `env:"CONFIG_URL"`
+			InstanceID string         `http:"@URL||/latest/meta-data/instance-id"`
+			Raw        []byte         `http:"@URL||/latest/meta-data/instance-id"`
+			Document   map[string]any `http:"@URL||/app.json"`
+		}](ctx, env.New(), New())
+
+		require.NoError(t, err)
+		assert.Equal(t, "i-0123456789", result.InstanceID)
+		assert.Equal(t, []byte("i-0123456789\n"), result.Raw)
+		assert.Contains(t, result.Document, "db")
+	})
+
+	t.Run("Sends headers built from other fields", func(t *testing.T) {
+		service := setupMockConfigService(t, map[string]string{"/app.json": appDocument})
+		service.Token = "s3cr3t:token"
+		t.Setenv("CONFIG_TOKEN", service.Token)
+
+		result, err := Load[struct {
+			URL   string `env:"CONFIG_URL"`
+			Token string `env:"CONFIG_TOKEN"`
+			Host  string `http:"@URL||/app.json#/db/host; Authorization: Bearer @Token"`
+		}](ctx, env.New(), New())
+
+		require.NoError(t, err)
+		assert.Equal(t, "db.internal", result.Host)
+
+		_, err = Load[struct {
+			URL  string `env:"CONFIG_URL"`
+			Host string `http:"@URL||/app.json#/db/host"`
+		}](ctx, env.New(), New())
+
+		assert.ErrorContains(t, err, "401 Unauthorized")
+	})
+
+	t.Run("Sends headers from options", func(t *testing.T) {
+		service := setupMockConfigService(t, map[string]string{"/app.json": appDocument})
+		service.Token = "token"
+
+		result, err := Load[struct {
+			URL  string `env:"CONFIG_URL"`
+			Host string `http:"@URL||/app.json#/db/host"`
+		}](ctx, env.New(), New(WithHeader("Authorization", "Bearer token")))
+
+		require.NoError(t, err)
+		assert.Equal(t, "db.internal", result.Host)
+	})
+
+	t.Run("Fetches each document once per batch", func(t *testing.T) {
+		service := setupMockConfigService(t, map[string]string{"/app.json": appDocument})
+
+		_, err := Load[struct {
+			URL   string `env:"CONFIG_URL"`
+			Host  string `http:"@URL||/app.json#/db/host"`
+			Port  int    `http:"@URL||/app.json#/db/port"`
+			Debug bool   `http:"@URL||/app.json#/debug"`
+		}](ctx, env.New(), New())
+
+		require.NoError(t, err)
+		assert.Equal(t, 1, service.Requests["/app.json"])
+	})
+
+	t.Run("Revalidates documents with their ETag", func(t *testing.T) {
+		service := setupMockConfigService(t, map[string]string{"/app.json": appDocument})
+		loader := New()
+
+		type config struct {
+			URL  string `env:"CONFIG_URL"`
+			Host string `http:"@URL||/app.json#/db/host"`
+		}
+
+		for range 3 {
+			result, err := Load[config](ctx, env.New(), loader)
+			require.NoError(t, err)
+			assert.Equal(t, "db.internal", result.Host)
+		}
+		assert.Equal(t, 3, service.Requests["/app.json"])
+		assert.Equal(t, 1, service.Downloads["/app.json"])
+
+		service.Set("/app.json", `{"db": {"host": "new.internal"}}`)
+		result, err := Load[config](ctx, env.New(), loader)
+		require.NoError(t, err)
+		assert.Equal(t, "new.internal", result.Host)
+		assert.Equal(t, 2, service.Downloads["/app.json"])
+	})
+
+	t.Run("Forgets documents once they are missing", func(t *testing.T) {
+		service := setupMockConfigService(t, map[string]string{"/app.json": appDocument})
+		loader := New()
+
+		type config struct {
+			URL  string `env:"CONFIG_URL"`
+			Host string `http:"@URL||/app.json#/db/host?"`
+		}
+
+		_, err := Load[config](ctx, env.New(), loader)
+		require.NoError(t, err)
+
+		service.Remove("/app.json")
+		result, err := Load[config](ctx, env.New(), loader)
+		require.NoError(t, err)
+		assert.Empty(t, result.Host)
+
+		service.Set("/app.json", appDocument)
+		result, err = Load[config](ctx, env.New(), loader)
+		require.NoError(t, err)
+		assert.Equal(t, "db.internal", result.Host)
+		assert.Equal(t, 2, service.Downloads["/app.json"])
+	})
+
+	t.Run("Keeps a bounded number of documents", func(t *testing.T) {
+		service := setupMockConfigService(t, map[string]string{"/app.json": appDocument})
+		loader := New()
+
+		type config struct {
+			URL   string `env:"CONFIG_URL"`
+			Token string `env:"CONFIG_TOKEN"`
+			Host  string `http:"@URL||/app.json#/db/host; X-Token: @Token"`
+		}
+
+		// Every rotated token is another request to revalidate
+		for i := range 101 {
+			t.Setenv("CONFIG_TOKEN", fmt.Sprint(i))
+			_, err := Load[config](ctx, env.New(), loader)
+			require.NoError(t, err)
+		}
+		assert.Equal(t, 101, service.Downloads["/app.json"])
+
+		t.Setenv("CONFIG_TOKEN", "100")
+		_, err := Load[config](ctx, env.New(), loader)
+		require.NoError(t, err)
+		assert.Equal(t, 101, service.Downloads["/app.json"])
+
+		t.Setenv("CONFIG_TOKEN", "0")
+		_, err = Load[config](ctx, env.New(), loader)
+		require.NoError(t, err)
+		assert.Equal(t, 102, service.Downloads["/app.json"])
+	})
+
+	t.Run("Handles missing documents and values", func(t *testing.T) {
+		setupMockConfigService(t, map[string]string{"/app.json": appDocument})
+
+		result, err := Load[struct {
+			URL      string `env:"CONFIG_URL"`
+			Missing  string `http:"@URL||/missing.json#/db/host?"`
+			NoKey    string `http:"@URL||/app.json#/db/user?"`
+			NoIndex  string `http:"@URL||/app.json#/db/replicas/2?"`
+			Fallback string `http:"@URL||/missing.json#/db/host?" default:"localhost"`
+		}](ctx, env.New(), New())
+
+		require.NoError(t, err)
+		assert.Empty(t, result.Missing)
+		assert.Empty(t, result.NoKey)
+		assert.Empty(t, result.NoIndex)
+		assert.Equal(t, "localhost", result.Fallback)
+
+		_, err = Load[struct {
+			URL  string `env:"CONFIG_URL"`
+			Host string `http:"@URL||/missing.json#/db/host"`
+		}](ctx, env.New(), New())
+
+		assert.ErrorIs(t, err, utils.ErrMissingRequired)
+	})
+
+	t.Run("Limits the size of responses", func(t *testing.T) {
+		setupMockConfigService(t, map[string]string{
+			"/large.json": `{"value": "` + strings.Repeat("x", 100) + `"}`,
+		})
+
+		type config struct {
+			URL   string `env:"CONFIG_URL"`
+			Value string `http:"@URL||/large.json#/value"`
+		}
+
+		_, err := Load[config](ctx, env.New(), New(WithMaxBytes(64)))
+		assert.ErrorContains(t, err, "response larger than 64 bytes")
+
+		result, err := Load[config](ctx, env.New(), New(WithMaxBytes(128)))
+		require.NoError(t, err)
+		assert.Len(t, result.Value, 100)
+	})
+
+	t.Run("Errors on invalid tags", func(t *testing.T) {
+		_, err := Load[struct {
+			Value string `http:"ftp://cfg.internal/app.json"`
+		}](ctx, New())
+		assert.ErrorContains(t, err, "expected an http or https URL")
+
+		_, err = Load[struct {
+			Value string `http:"https://cfg.internal/app.json#db/host"`
+		}](ctx, New())
+		assert.ErrorContains(t, err, "expected it to start with /")
+
+		_, err = Load[struct {
+			Value string `http:"https://cfg.internal/app.json; Authorization"`
+		}](ctx, New())
+		assert.ErrorContains(t, err, "expected headers as name: value")
+	})
+}
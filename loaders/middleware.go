@@ -0,0 +1,159 @@
+package loaders
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"time"
+
+	"github.com/Gardego5/gocfg"
+	"github.com/Gardego5/gocfg/utils"
+)
+
+// Clock tells time for the middleware, so tests can control it
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// Option configures a middleware
+type Option func(*options)
+
+type options struct{ clock Clock }
+
+// UsingClock makes a middleware tell time with clock instead of the time
+// package
+func UsingClock(clock Clock) Option {
+	return func(o *options) { o.clock = clock }
+}
+
+func newOptions(opts []Option) options {
+	o := options{clock: realClock{}}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// wrapper is embedded by loaders wrapping another loader, and forwards the
+// optional interfaces that don't load values
+type wrapper struct{ inner gocfg.Loader }
+
+func (w wrapper) GocfgLoaderName() string { return w.inner.GocfgLoaderName() }
+
+func (w wrapper) GocfgUntaggedTag(field reflect.StructField) (string, bool) {
+	if untagged, ok := w.inner.(gocfg.UntaggedLoader); ok {
+		return untagged.GocfgUntaggedTag(field)
+	}
+	return "", false
+}
+
+func (w wrapper) GocfgDefault(field reflect.StructField, tag string) (string, bool) {
+	if defaulter, ok := w.inner.(gocfg.DefaultLoader); ok {
+		return defaulter.GocfgDefault(field, tag)
+	}
+	return "", false
+}
+
+func (w wrapper) GocfgEscapedTags() bool {
+	escaped, ok := w.inner.(gocfg.EscapedTagLoader)
+	return ok && escaped.GocfgEscapedTags()
+}
+
+func (w wrapper) GocfgRawTags() bool {
+	raw, ok := w.inner.(gocfg.RawTagLoader)
+	return ok && raw.GocfgRawTags()
+}
+
+func (w wrapper) GocfgFinalize(ctx context.Context) error {
+	if finalizer, ok := w.inner.(gocfg.Finalizer); ok {
+		return finalizer.GocfgFinalize(ctx)
+	}
+	return nil
+}
+
+// wrapping is a loader embedding wrapper
+type wrapping interface {
+	gocfg.UntaggedLoader
+	gocfg.DefaultLoader
+	gocfg.EscapedTagLoader
+	gocfg.RawTagLoader
+	gocfg.Finalizer
+}
+
+type loadBatchFunc func(ctx context.Context, requests []gocfg.FieldRequest) []error
+
+// forward returns w, implementing gocfg.DependencyDeclarer and
+// gocfg.BatchLoader as well when the loader it wraps does. Those change how
+// the core calls a loader, so they can't be implemented unconditionally.
+// Batches are loaded with loadBatch.
+func forward(w wrapping, inner gocfg.Loader, loadBatch loadBatchFunc) gocfg.Loader {
+	declarer, isDeclarer := inner.(gocfg.DependencyDeclarer)
+	_, isBatchLoader := inner.(gocfg.BatchLoader)
+
+	switch {
+	case isDeclarer && isBatchLoader:
+		return &declaringBatchLoader{declaringLoader{w, declarer}, loadBatch}
+	case isDeclarer:
+		return &declaringLoader{w, declarer}
+	case isBatchLoader:
+		return &batchLoader{w, loadBatch}
+	default:
+		return w
+	}
+}
+
+type declaringLoader struct {
+	wrapping
+	declarer gocfg.DependencyDeclarer
+}
+
+func (l *declaringLoader) GocfgDependencies(field reflect.StructField, tag string) []string {
+	return l.declarer.GocfgDependencies(field, tag)
+}
+
+type batchLoader struct {
+	wrapping
+	loadBatch loadBatchFunc
+}
+
+func (l *batchLoader) LoadBatch(ctx context.Context, requests []gocfg.FieldRequest) []error {
+	return l.loadBatch(ctx, requests)
+}
+
+type declaringBatchLoader struct {
+	declaringLoader
+	loadBatch loadBatchFunc
+}
+
+func (l *declaringBatchLoader) LoadBatch(ctx context.Context, requests []gocfg.FieldRequest) []error {
+	return l.loadBatch(ctx, requests)
+}
+
+// isFailure reports whether err means the loader's backend failed, as with
+// network errors and timeouts, rather than answering that it has no value,
+// the configuration being wrong, or the caller giving up. Only failures are
+// retried, count towards opening circuits, and are served from caches.
+func isFailure(ctx context.Context, err error) bool {
+	if err == nil || ctx.Err() != nil {
+		return false
+	}
+
+	var syntaxErr *gocfg.SyntaxError
+	switch {
+	case errors.Is(err, utils.ErrNotFound), errors.Is(err, utils.ErrMissingRequired):
+		return false // No value
+	case errors.As(err, &syntaxErr), errors.Is(err, utils.ErrInvalidTag),
+		errors.Is(err, utils.ErrInvalidValue), errors.Is(err, utils.ErrConflictingSources),
+		errors.Is(err, utils.ErrUnboundVariable), errors.Is(err, utils.ErrCircularDependency):
+		return false // Fails the same way until the configuration is fixed
+	case errors.Is(err, ErrCircuitOpen):
+		return false
+	}
+	return true
+}
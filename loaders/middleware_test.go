@@ -0,0 +1,422 @@
+package loaders_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Gardego5/gocfg"
+	"github.com/Gardego5/gocfg/loaders"
+	"github.com/Gardego5/gocfg/loaders/env"
+	"github.com/Gardego5/gocfg/loaders/tmpl"
+	"github.com/Gardego5/gocfg/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeClock is a Clock whose time only moves when advanced. With auto set,
+// waiting advances it instead, so waits return at once.
+type fakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	auto   bool
+	waits  []time.Duration
+	timers []fakeTimer
+}
+
+type fakeTimer struct {
+	at time.Time
+	ch chan time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.waits = append(c.waits, d)
+	ch := make(chan time.Time, 1)
+	if c.auto {
+		c.now = c.now.Add(d)
+		ch <- c.now
+	} else {
+		c.timers = append(c.timers, fakeTimer{at: c.now.Add(d), ch: ch})
+	}
+	return ch
+}
+
+// Advance moves the clock forward, firing the timers that are due
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+	pending := c.timers[:0]
+	for _, timer := range c.timers {
+		if timer.at.After(c.now) {
+			pending = append(pending, timer)
+		} else {
+			timer.ch <- c.now
+		}
+	}
+	c.timers = pending
+}
+
+// WaitForTimer blocks until something waits on the clock
+func (c *fakeClock) WaitForTimer(t *testing.T) {
+	require.Eventually(t, func() bool {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return len(c.timers) > 0
+	}, time.Second, time.Millisecond)
+}
+
+// failingLoader fails its first failures loads with err, then loads tags
+type failingLoader struct {
+	failures int
+	err      error
+	calls    int
+}
+
+func (*failingLoader) GocfgLoaderName() string { return "flaky" }
+
+func (l *failingLoader) Load(_ context.Context, _ reflect.StructField, value reflect.Value, tag string) error {
+	l.calls++
+	if l.calls <= l.failures {
+		return l.err
+	}
+	return utils.SetFieldValue(value, tag)
+}
+
+// batchingLoader is a failingLoader that loads batches, failing the fields
+// with tags in failing once
+type batchingLoader struct {
+	failingLoader
+	failing map[string]bool
+	batches [][]string
+}
+
+func (l *batchingLoader) LoadBatch(_ context.Context, requests []gocfg.FieldRequest) []error {
+	var tags []string
+	errs := make([]error, len(requests))
+	for i, request := range requests {
+		tags = append(tags, request.Tag)
+		if l.failing[request.Tag] {
+			delete(l.failing, request.Tag)
+			errs[i] = errors.New("unavailable")
+			continue
+		}
+		errs[i] = utils.SetFieldValue(request.Value, request.Tag)
+	}
+	l.batches = append(l.batches, tags)
+	return errs
+}
+
+type flakyConfig struct {
+	Value string `flaky:"loaded"`
+}
+
+func TestWithRetry(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Retries failed loads with backoff", func(t *testing.T) {
+		clock := &fakeClock{auto: true}
+		inner := &failingLoader{failures: 3, err: errors.New("unavailable")}
+		loader := loaders.WithRetry(inner, loaders.ExponentialBackoff(10*time.Millisecond, time.Second, 5), loaders.UsingClock(clock))
+
+		result, err := gocfg.Load[flakyConfig](ctx, loader)
+
+		require.NoError(t, err)
+		assert.Equal(t, "loaded", result.Value)
+		assert.Equal(t, 4, inner.calls)
+		assert.Equal(t, []time.Duration{10 * time.Millisecond, 20 * time.Millisecond, 40 * time.Millisecond}, clock.waits)
+	})
+
+	t.Run("Gives up after the last retry", func(t *testing.T) {
+		clock := &fakeClock{auto: true}
+		unavailable := errors.New("unavailable")
+		inner := &failingLoader{failures: 10, err: unavailable}
+		loader := loaders.WithRetry(inner, loaders.ConstantBackoff(time.Second, 2), loaders.UsingClock(clock))
+
+		_, err := gocfg.Load[flakyConfig](ctx, loader)
+
+		require.ErrorIs(t, err, unavailable)
+		assert.Equal(t, 3, inner.calls)
+		assert.Equal(t, []time.Duration{time.Second, time.Second}, clock.waits)
+	})
+
+	t.Run("Does not retry missing values", func(t *testing.T) {
+		clock := &fakeClock{auto: true}
+		inner := &failingLoader{failures: 10, err: utils.NotFoundError(false, "not found")}
+		loader := loaders.WithRetry(inner, loaders.ConstantBackoff(time.Second, 2), loaders.UsingClock(clock))
+
+		_, err := gocfg.Load[flakyConfig](ctx, loader)
+
+		require.ErrorIs(t, err, utils.ErrMissingRequired)
+		assert.Equal(t, 1, inner.calls)
+		assert.Empty(t, clock.waits)
+	})
+
+	t.Run("Does not retry configuration errors", func(t *testing.T) {
+		for name, err := range map[string]error{
+			"invalid tag":         utils.InvalidTagError("invalid tag %q", "x"),
+			"invalid value":       utils.SetFieldValue(reflect.ValueOf(new(int)).Elem(), "x"),
+			"conflicting sources": utils.ErrConflictingSources,
+		} {
+			t.Run(name, func(t *testing.T) {
+				clock := &fakeClock{auto: true}
+				inner := &failingLoader{failures: 10, err: err}
+				loader := loaders.WithRetry(inner, loaders.ConstantBackoff(time.Second, 2), loaders.UsingClock(clock))
+
+				_, loadErr := gocfg.Load[flakyConfig](ctx, loader)
+
+				require.ErrorIs(t, loadErr, err)
+				assert.Equal(t, 1, inner.calls)
+				assert.Empty(t, clock.waits)
+			})
+		}
+	})
+
+	t.Run("Stops waiting when the context is done", func(t *testing.T) {
+		clock := &fakeClock{}
+		inner := &failingLoader{failures: 10, err: errors.New("unavailable")}
+		loader := loaders.WithRetry(inner, loaders.ConstantBackoff(time.Hour, 2), loaders.UsingClock(clock))
+
+		ctx, cancel := context.WithCancel(ctx)
+		done := make(chan error)
+		go func() {
+			_, err := gocfg.Load[flakyConfig](ctx, loader)
+			done <- err
+		}()
+
+		clock.WaitForTimer(t)
+		cancel()
+
+		require.Error(t, <-done)
+		assert.Equal(t, 1, inner.calls)
+	})
+
+	t.Run("Retries only the failed fields of a batch", func(t *testing.T) {
+		clock := &fakeClock{auto: true}
+		inner := &batchingLoader{failing: map[string]bool{"b": true}}
+		loader := loaders.WithRetry(inner, loaders.ConstantBackoff(time.Second, 2), loaders.UsingClock(clock))
+
+		result, err := gocfg.Load[struct {
+			A string `flaky:"a"`
+			B string `flaky:"b"`
+		}](ctx, loader)
+
+		require.NoError(t, err)
+		assert.Equal(t, "a", result.A)
+		assert.Equal(t, "b", result.B)
+		assert.Equal(t, [][]string{{"a", "b"}, {"b"}}, inner.batches)
+	})
+
+	t.Run("Caps exponential backoff", func(t *testing.T) {
+		backoff := loaders.ExponentialBackoff(time.Second, 5*time.Second, 10)
+
+		for attempt, expected := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second} {
+			delay, ok := backoff(attempt + 1)
+			assert.True(t, ok)
+			assert.Equal(t, expected, delay)
+		}
+
+		_, ok := backoff(11)
+		assert.False(t, ok)
+	})
+}
+
+func TestWithTimeout(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Fails loads that take too long", func(t *testing.T) {
+		clock := &fakeClock{}
+		canceled := make(chan struct{})
+		inner := &MockLoader{
+			NameToReturn: "flaky",
+			LoadFunc: func(ctx context.Context, _ reflect.StructField, value reflect.Value, _ string) error {
+				<-ctx.Done()
+				close(canceled)
+				value.SetString("too late")
+				return ctx.Err()
+			},
+		}
+		loader := loaders.WithTimeout(inner, 5*time.Second, loaders.UsingClock(clock))
+
+		done := make(chan error)
+		var result flakyConfig
+		go func() {
+			var err error
+			result, err = gocfg.Load[flakyConfig](ctx, loader)
+			done <- err
+		}()
+
+		clock.WaitForTimer(t)
+		clock.Advance(5 * time.Second)
+
+		err := <-done
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Contains(t, err.Error(), "flaky timed out after 5s")
+		assert.Empty(t, result.Value)
+		<-canceled
+	})
+
+	t.Run("Keeps abandoned loads out of the provenance", func(t *testing.T) {
+		clock := &fakeClock{}
+		marked := make(chan struct{})
+		timeout := loaders.WithTimeout(&MockLoader{
+			NameToReturn: "flaky",
+			LoadFunc: func(ctx context.Context, _ reflect.StructField, _ reflect.Value, _ string) error {
+				<-ctx.Done()
+				gocfg.MarkCached(ctx, time.Now())
+				close(marked)
+				return ctx.Err()
+			},
+		}, 5*time.Second, loaders.UsingClock(clock))
+
+		// Falls back to a value of its own once the abandoned load is done
+		loader := &MockLoader{
+			NameToReturn: "flaky",
+			LoadFunc: func(ctx context.Context, field reflect.StructField, value reflect.Value, tag string) error {
+				assert.ErrorIs(t, timeout.Load(ctx, field, value, tag), context.DeadlineExceeded)
+				<-marked
+				value.SetString("fallback")
+				return nil
+			},
+		}
+
+		done := make(chan gocfg.Provenance)
+		go func() {
+			_, provenance, err := gocfg.LoadWithProvenance[flakyConfig](ctx, loader)
+			assert.NoError(t, err)
+			done <- provenance
+		}()
+
+		clock.WaitForTimer(t)
+		clock.Advance(5 * time.Second)
+
+		assert.Equal(t, gocfg.Origin{Tag: "flaky"}, (<-done)["Value"])
+	})
+
+	t.Run("Passes through loads that finish in time", func(t *testing.T) {
+		loader := loaders.WithTimeout(&failingLoader{}, 5*time.Second, loaders.UsingClock(&fakeClock{}))
+
+		result, err := gocfg.Load[flakyConfig](ctx, loader)
+
+		require.NoError(t, err)
+		assert.Equal(t, "loaded", result.Value)
+	})
+
+	t.Run("Loads batches", func(t *testing.T) {
+		inner := &batchingLoader{}
+		loader := loaders.WithTimeout(inner, 5*time.Second, loaders.UsingClock(&fakeClock{}))
+
+		result, err := gocfg.Load[struct {
+			A string `flaky:"a"`
+			B string `flaky:"b"`
+		}](ctx, loader)
+
+		require.NoError(t, err)
+		assert.Equal(t, "a", result.A)
+		assert.Equal(t, "b", result.B)
+		assert.Len(t, inner.batches, 1)
+	})
+}
+
+func TestWithCircuitBreaker(t *testing.T) {
+	ctx := context.Background()
+	unavailable := errors.New("unavailable")
+
+	t.Run("Fails fast after consecutive failures", func(t *testing.T) {
+		clock := &fakeClock{}
+		inner := &failingLoader{failures: 4, err: unavailable}
+		loader := loaders.WithCircuitBreaker(inner, 3, time.Minute, loaders.UsingClock(clock))
+
+		for i := 0; i < 3; i++ {
+			_, err := gocfg.Load[flakyConfig](ctx, loader)
+			require.ErrorIs(t, err, unavailable)
+		}
+
+		_, err := gocfg.Load[flakyConfig](ctx, loader)
+		require.ErrorIs(t, err, loaders.ErrCircuitOpen)
+		assert.Equal(t, 3, inner.calls)
+
+		// A failed probe keeps the circuit open for another cooldown
+		clock.Advance(time.Minute)
+		_, err = gocfg.Load[flakyConfig](ctx, loader)
+		require.ErrorIs(t, err, unavailable)
+		_, err = gocfg.Load[flakyConfig](ctx, loader)
+		require.ErrorIs(t, err, loaders.ErrCircuitOpen)
+		assert.Equal(t, 4, inner.calls)
+
+		// A successful probe closes it
+		clock.Advance(time.Minute)
+		result, err := gocfg.Load[flakyConfig](ctx, loader)
+		require.NoError(t, err)
+		assert.Equal(t, "loaded", result.Value)
+
+		_, err = gocfg.Load[flakyConfig](ctx, loader)
+		require.NoError(t, err)
+		assert.Equal(t, 6, inner.calls)
+	})
+
+	t.Run("Does not count missing values as failures", func(t *testing.T) {
+		inner := &failingLoader{failures: 10, err: utils.NotFoundError(true, "not found")}
+		loader := loaders.WithCircuitBreaker(inner, 1, time.Minute, loaders.UsingClock(&fakeClock{}))
+
+		for i := 0; i < 3; i++ {
+			_, err := gocfg.Load[flakyConfig](ctx, loader)
+			require.NoError(t, err)
+		}
+		assert.Equal(t, 3, inner.calls)
+	})
+
+	t.Run("Is not retried while open", func(t *testing.T) {
+		clock := &fakeClock{auto: true}
+		inner := &failingLoader{failures: 10, err: unavailable}
+		loader := loaders.WithRetry(
+			loaders.WithCircuitBreaker(inner, 2, time.Hour, loaders.UsingClock(clock)),
+			loaders.ConstantBackoff(time.Second, 5), loaders.UsingClock(clock),
+		)
+
+		_, err := gocfg.Load[flakyConfig](ctx, loader)
+
+		require.ErrorIs(t, err, loaders.ErrCircuitOpen)
+		assert.Equal(t, 2, inner.calls)
+	})
+}
+
+func TestMiddlewareForwarding(t *testing.T) {
+	t.Run("Keeps optional interfaces of the wrapped loader", func(t *testing.T) {
+		for _, loader := range []gocfg.Loader{
+			loaders.WithRetry(tmpl.New(), loaders.ConstantBackoff(0, 1)),
+			loaders.WithTimeout(tmpl.New(), time.Second),
+			loaders.WithCircuitBreaker(tmpl.New(), 1, time.Second),
+		} {
+			assert.Implements(t, (*gocfg.DependencyDeclarer)(nil), loader)
+			assert.NotImplements(t, (*gocfg.BatchLoader)(nil), loader)
+			assert.True(t, loader.(gocfg.RawTagLoader).GocfgRawTags())
+			assert.Equal(t, "tmpl", loader.GocfgLoaderName())
+		}
+
+		batching := loaders.WithTag("custom", loaders.WithTimeout(&batchingLoader{}, time.Second))
+		assert.Implements(t, (*gocfg.BatchLoader)(nil), batching)
+		assert.NotImplements(t, (*gocfg.DependencyDeclarer)(nil), batching)
+	})
+
+	t.Run("Reads escaped tags only when the wrapped loader does", func(t *testing.T) {
+		escaped := loaders.WithTag("custom", loaders.WithRetry(env.New(), loaders.ConstantBackoff(0, 1)))
+		assert.True(t, escaped.(gocfg.EscapedTagLoader).GocfgEscapedTags())
+
+		unescaped := loaders.WithTag("custom", loaders.WithTimeout(&batchingLoader{}, time.Second))
+		assert.False(t, unescaped.(gocfg.EscapedTagLoader).GocfgEscapedTags())
+	})
+}
@@ -0,0 +1,112 @@
+package loaders
+
+import (
+	"context"
+	"reflect"
+	"time"
+
+	"github.com/Gardego5/gocfg"
+)
+
+// Backoff returns how long to wait before retry number attempt, counting
+// from 1, or false to stop retrying
+type Backoff func(attempt int) (time.Duration, bool)
+
+// ConstantBackoff retries up to retries times, waiting delay before each
+func ConstantBackoff(delay time.Duration, retries int) Backoff {
+	return func(attempt int) (time.Duration, bool) {
+		return delay, attempt <= retries
+	}
+}
+
+// ExponentialBackoff retries up to retries times, waiting initial before the
+// first retry and doubling the wait for each one after, up to max
+func ExponentialBackoff(initial, max time.Duration, retries int) Backoff {
+	return func(attempt int) (time.Duration, bool) {
+		delay := initial
+		for i := 1; i < attempt && delay < max; i++ {
+			delay *= 2
+		}
+		return min(delay, max), attempt <= retries
+	}
+}
+
+type retryLoader struct {
+	wrapper
+	backoff Backoff
+	clock   Clock
+}
+
+// WithRetry retries loads that fail, waiting between attempts as backoff
+// says. Errors meaning there is no value, like utils.ErrNotFound, and
+// ErrCircuitOpen are not retried. Batches are retried for the fields that
+// failed.
+func WithRetry(loader gocfg.Loader, backoff Backoff, opts ...Option) gocfg.Loader {
+	r := &retryLoader{wrapper: wrapper{loader}, backoff: backoff, clock: newOptions(opts).clock}
+	return forward(r, loader, r.loadBatch)
+}
+
+func (r *retryLoader) Load(
+	ctx context.Context,
+	field reflect.StructField, value reflect.Value,
+	resolvedTag string,
+) error {
+	for attempt := 1; ; attempt++ {
+		err := r.inner.Load(ctx, field, value, resolvedTag)
+		if !isFailure(ctx, err) {
+			return err
+		}
+
+		if !r.wait(ctx, attempt) {
+			return err
+		}
+	}
+}
+
+func (r *retryLoader) loadBatch(ctx context.Context, requests []gocfg.FieldRequest) []error {
+	errs := r.inner.(gocfg.BatchLoader).LoadBatch(ctx, requests)
+	if len(errs) != len(requests) {
+		return errs // Reported by the core
+	}
+
+	for attempt := 1; ; attempt++ {
+		var retries []int // Indexes of the requests to retry
+		for i, err := range errs {
+			if isFailure(ctx, err) {
+				retries = append(retries, i)
+			}
+		}
+		if len(retries) == 0 || !r.wait(ctx, attempt) {
+			return errs
+		}
+
+		retried := make([]gocfg.FieldRequest, len(retries))
+		for j, i := range retries {
+			retried[j] = requests[i]
+		}
+
+		retryErrs := r.inner.(gocfg.BatchLoader).LoadBatch(ctx, retried)
+		if len(retryErrs) != len(retried) {
+			return retryErrs
+		}
+		for j, i := range retries {
+			errs[i] = retryErrs[j]
+		}
+	}
+}
+
+// wait waits before retry number attempt, returning false when there are no
+// more retries or ctx is done first
+func (r *retryLoader) wait(ctx context.Context, attempt int) bool {
+	delay, ok := r.backoff(attempt)
+	if !ok {
+		return false
+	}
+
+	select {
+	case <-r.clock.After(delay):
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
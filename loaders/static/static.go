@@ -0,0 +1,58 @@
+package static
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/Gardego5/gocfg"
+	"github.com/Gardego5/gocfg/utils"
+)
+
+// StaticLoader loads configuration from a fixed map of values, for tests and
+// for values known when the program starts. Use loaders.WithTag to stand in
+// for another loader, as in loaders.WithTag("env", static.New(values)).
+func New(values map[string]string) gocfg.Loader {
+	copied := make(map[string]string, len(values))
+	for key, value := range values {
+		copied[key] = value
+	}
+	return &loader{values: copied}
+}
+
+type loader struct{ values map[string]string }
+
+func (*loader) GocfgLoaderName() string { return "static" }
+func (*loader) GocfgEscapedTags() bool  { return true }
+
+// Load implements the Loader interface for a map of values
+// Tag formats supported:
+// - "key" - The value of key
+// - "key?" - Optional key
+// - "key=default" - Key with a default value, which may be empty
+// - "@Field||.suffix" - Build the key from other fields
+func (l *loader) Load(
+	ctx context.Context,
+	field reflect.StructField, value reflect.Value,
+	resolvedTag string,
+) error {
+	// Handle special case - fully resolved reference or concatenation
+	if strings.HasPrefix(resolvedTag, "@") || strings.Contains(resolvedTag, "||") {
+		// At this point the tag should be resolved already
+		return fmt.Errorf("unexpected unresolved tag: %s", resolvedTag)
+	}
+
+	tag, isOptional := utils.CutTagSuffix(strings.TrimSpace(resolvedTag), "?")
+	key, defaultValue, hasDefault := utils.CutTag(tag, "=")
+	key = utils.UnescapeTag(strings.TrimSpace(key))
+
+	if text, exists := l.values[key]; exists {
+		return utils.SetFieldValue(value, text)
+	}
+	if hasDefault {
+		return utils.SetFieldValue(value, utils.UnescapeTag(defaultValue))
+	}
+
+	return utils.NotFoundError(isOptional, "key %s not found", key)
+}
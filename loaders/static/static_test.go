@@ -0,0 +1,96 @@
+package static_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/Gardego5/gocfg"
+	"github.com/Gardego5/gocfg/loaders"
+	. "github.com/Gardego5/gocfg/loaders/static"
+	"github.com/Gardego5/gocfg/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStaticLoader(t *testing.T) {
+	ctx := context.Background()
+
+	values := map[string]string{
+		"host":         "db.internal",
+		"port":         "5432",
+		"timeout":      "5s",
+		"tenant.acme":  "acme-db",
+		"team@app:key": "escaped",
+	}
+
+	t.Run("Loads values by key", func(t *testing.T) {
+		result, err := Load[struct {
+			Host    string        `static:"host"`
+			Port    int           `static:"port"`
+			Timeout time.Duration `static:"timeout"`
+		}](ctx, New(values))
+
+		require.NoError(t, err)
+		assert.Equal(t, "db.internal", result.Host)
+		assert.Equal(t, 5432, result.Port)
+		assert.Equal(t, 5*time.Second, result.Timeout)
+	})
+
+	t.Run("Handles optional keys and defaults", func(t *testing.T) {
+		result, err := Load[struct {
+			Optional string `static:"missing?"`
+			Default  string `static:"missing=fallback"`
+			Empty    string `static:"missing="`
+			Present  string `static:"host=fallback"`
+		}](ctx, New(values))
+
+		require.NoError(t, err)
+		assert.Empty(t, result.Optional)
+		assert.Equal(t, "fallback", result.Default)
+		assert.Empty(t, result.Empty)
+		assert.Equal(t, "db.internal", result.Present)
+	})
+
+	t.Run("Errors on missing required keys", func(t *testing.T) {
+		_, err := Load[struct {
+			Value string `static:"missing"`
+		}](ctx, New(values))
+
+		require.ErrorIs(t, err, utils.ErrMissingRequired)
+	})
+
+	t.Run("Builds keys from other fields", func(t *testing.T) {
+		result, err := Load[struct {
+			Tenant string `static:"tenant=acme"`
+			DB     string `static:"tenant.||@Tenant"`
+			Quoted string `static:"'team@app:key'"`
+		}](ctx, New(values))
+
+		require.NoError(t, err)
+		assert.Equal(t, "acme-db", result.DB)
+		assert.Equal(t, "escaped", result.Quoted)
+	})
+
+	t.Run("Is not affected by changes to the map", func(t *testing.T) {
+		changing := map[string]string{"key": "before"}
+		loader := New(changing)
+		changing["key"] = "after"
+
+		result, err := Load[struct {
+			Value string `static:"key"`
+		}](ctx, loader)
+
+		require.NoError(t, err)
+		assert.Equal(t, "before", result.Value)
+	})
+
+	t.Run("Stands in for other loaders", func(t *testing.T) {
+		result, err := Load[struct {
+			Host string `env:"DB_HOST"`
+		}](ctx, loaders.WithTag("env", New(map[string]string{"DB_HOST": "stand-in"})))
+
+		require.NoError(t, err)
+		assert.Equal(t, "stand-in", result.Host)
+	})
+}
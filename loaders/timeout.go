@@ -0,0 +1,87 @@
+package loaders
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/Gardego5/gocfg"
+)
+
+type timeoutLoader struct {
+	wrapper
+	timeout time.Duration
+	clock   Clock
+}
+
+// WithTimeout fails loads that take longer than timeout with an error
+// wrapping context.DeadlineExceeded, and cancels the context passed to the
+// loader. Loads ignoring their context are abandoned rather than waited for:
+// they load into a copy of the field, which is only set, along with the
+// field's provenance, if they finish in time.
+func WithTimeout(loader gocfg.Loader, timeout time.Duration, opts ...Option) gocfg.Loader {
+	t := &timeoutLoader{wrapper: wrapper{loader}, timeout: timeout, clock: newOptions(opts).clock}
+	return forward(t, loader, func(ctx context.Context, requests []gocfg.FieldRequest) []error {
+		return t.run(ctx, requests, t.inner.(gocfg.BatchLoader).LoadBatch)
+	})
+}
+
+func (t *timeoutLoader) Load(
+	ctx context.Context,
+	field reflect.StructField, value reflect.Value,
+	resolvedTag string,
+) error {
+	request := gocfg.FieldRequest{Field: field, Value: value, Tag: resolvedTag}.WithContext(ctx)
+	return t.run(ctx, []gocfg.FieldRequest{request}, func(_ context.Context, requests []gocfg.FieldRequest) []error {
+		return []error{t.inner.Load(requests[0].Context(), field, requests[0].Value, resolvedTag)}
+	})[0]
+}
+
+// run loads copies of the requested fields in the background, with their
+// provenance detached, keeping the copies loaded before the timeout
+func (t *timeoutLoader) run(ctx context.Context, requests []gocfg.FieldRequest, load loadBatchFunc) []error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	copies := make([]gocfg.FieldRequest, len(requests))
+	keep := make([]func(), len(requests))
+	for i, request := range requests {
+		requestCtx, cancelRequest := context.WithCancel(request.Context())
+		defer cancelRequest()
+
+		requestCtx, keep[i] = gocfg.DetachProvenance(requestCtx)
+		copies[i] = request.WithContext(requestCtx)
+		copies[i].Value = reflect.New(request.Value.Type()).Elem()
+		copies[i].Value.Set(request.Value)
+	}
+
+	done := make(chan []error, 1)
+	go func() { done <- load(ctx, copies) }()
+
+	var err error
+	select {
+	case errs := <-done:
+		if len(errs) == len(requests) {
+			for i, request := range requests {
+				if errs[i] == nil {
+					request.Value.Set(copies[i].Value)
+					keep[i]()
+				}
+			}
+		}
+		return errs
+
+	case <-t.clock.After(t.timeout):
+		err = fmt.Errorf("%s timed out after %s: %w", t.GocfgLoaderName(), t.timeout, context.DeadlineExceeded)
+
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	errs := make([]error, len(requests))
+	for i := range errs {
+		errs[i] = err
+	}
+	return errs
+}
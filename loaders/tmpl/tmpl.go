@@ -0,0 +1,197 @@
+package tmpl
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"text/template"
+	"text/template/parse"
+
+	"github.com/Gardego5/gocfg"
+	"github.com/Gardego5/gocfg/utils"
+)
+
+// unknownDot is the dot of template nodes where it isn't a known field, as
+// inside {{range}}
+const unknownDot = "?"
+
+// Option configures the template loader
+type Option func(*loader)
+
+// WithFuncs adds functions templates can call, like template.Funcs
+func WithFuncs(funcs template.FuncMap) Option {
+	return func(l *loader) {
+		for name, fn := range funcs {
+			l.funcs[name] = fn
+		}
+	}
+}
+
+// TemplateLoader derives strings from other config fields with text/template
+func New(opts ...Option) gocfg.Loader {
+	l := &loader{
+		funcs:     make(template.FuncMap),
+		templates: make(map[string]*template.Template),
+	}
+
+	for _, opt := range opts {
+		opt(l)
+	}
+
+	return l
+}
+
+type loader struct {
+	funcs template.FuncMap
+
+	mu        sync.Mutex
+	templates map[string]*template.Template // Parsed templates by tag
+}
+
+func (*loader) GocfgLoaderName() string { return "tmpl" }
+func (*loader) GocfgRawTags() bool      { return true }
+
+// Load implements the Loader interface for templates
+// Tag formats supported:
+// - "postgres://{{.DBUser}}:{{.DBPass}}@{{.DBHost}}/{{.DBName}}" - A template
+// executed with the config struct as data
+// - "{{.Database.Host}}:{{.Database.Port}}" - Fields of nested structs
+//
+// Templates can read any field they name, which is loaded first. Inside
+// {{with .Database}}, fields relative to the dot are fields of Database;
+// inside {{range}} they can't be known, so range over fields loaded earlier.
+func (l *loader) Load(
+	ctx context.Context,
+	field reflect.StructField, value reflect.Value,
+	tag string,
+) error {
+	t, err := l.template(tag)
+	if err != nil {
+		return err
+	}
+
+	data := gocfg.PartialConfig(ctx)
+	if data == nil {
+		return fmt.Errorf("template for field %s must be loaded by gocfg.Load", field.Name)
+	}
+
+	var result strings.Builder
+	if err := t.Execute(&result, data); err != nil {
+		return fmt.Errorf("failed to execute template for field %s: %w", field.Name, err)
+	}
+
+	return utils.SetFieldValue(value, result.String())
+}
+
+// GocfgDependencies implements gocfg.DependencyDeclarer, returning the fields the
+// template reads
+func (l *loader) GocfgDependencies(field reflect.StructField, tag string) []string {
+	t, err := l.template(tag)
+	if err != nil {
+		return nil // Reported by Load
+	}
+
+	var deps []string
+	walk(t.Tree.Root, "", func(path string) { deps = append(deps, path) })
+	return deps
+}
+
+// template returns the parsed template for a tag
+func (l *loader) template(tag string) (*template.Template, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if t, exists := l.templates[tag]; exists {
+		return t, nil
+	}
+
+	t, err := template.New("tmpl").Funcs(l.funcs).Option("missingkey=error").Parse(tag)
+	if err != nil {
+		return nil, utils.InvalidTagError("invalid template %q: %w", tag, err)
+	}
+
+	l.templates[tag] = t
+	return t, nil
+}
+
+// walk calls visit with the dotted path of every config field a template
+// node reads. dot is the path of the dot, ending in ".", or "" for the config
+// itself; fields relative to an unknown dot are not visited.
+func walk(node parse.Node, dot string, visit func(path string)) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n != nil {
+			for _, child := range n.Nodes {
+				walk(child, dot, visit)
+			}
+		}
+
+	case *parse.ActionNode:
+		walk(n.Pipe, dot, visit)
+
+	case *parse.PipeNode:
+		if n != nil {
+			for _, cmd := range n.Cmds {
+				walk(cmd, dot, visit)
+			}
+		}
+
+	case *parse.CommandNode:
+		for _, arg := range n.Args {
+			walk(arg, dot, visit)
+		}
+
+	case *parse.FieldNode:
+		if dot != unknownDot {
+			visit(dot + strings.Join(n.Ident, "."))
+		}
+
+	case *parse.VariableNode:
+		// $ is always the config itself
+		if len(n.Ident) > 1 && n.Ident[0] == "$" {
+			visit(strings.Join(n.Ident[1:], "."))
+		}
+
+	case *parse.ChainNode:
+		walk(n.Node, dot, visit)
+
+	case *parse.IfNode:
+		walkBranch(&n.BranchNode, dot, dot, visit)
+
+	case *parse.WithNode:
+		walkBranch(&n.BranchNode, dot, pipeDot(n.Pipe, dot), visit)
+
+	case *parse.RangeNode:
+		walkBranch(&n.BranchNode, dot, unknownDot, visit)
+
+	case *parse.TemplateNode:
+		walk(n.Pipe, dot, visit)
+	}
+}
+
+func walkBranch(n *parse.BranchNode, dot, innerDot string, visit func(path string)) {
+	walk(n.Pipe, dot, visit)
+	walk(n.List, innerDot, visit)
+	walk(n.ElseList, dot, visit)
+}
+
+// pipeDot returns the dot inside {{with pipe}}: the path of a plain field,
+// or unknownDot
+func pipeDot(pipe *parse.PipeNode, dot string) string {
+	if dot == unknownDot || len(pipe.Decl) > 0 || len(pipe.Cmds) != 1 || len(pipe.Cmds[0].Args) != 1 {
+		return unknownDot
+	}
+
+	switch arg := pipe.Cmds[0].Args[0].(type) {
+	case *parse.FieldNode:
+		return dot + strings.Join(arg.Ident, ".") + "."
+	case *parse.VariableNode:
+		if len(arg.Ident) > 1 && arg.Ident[0] == "$" {
+			return strings.Join(arg.Ident[1:], ".") + "."
+		}
+	}
+
+	return unknownDot
+}
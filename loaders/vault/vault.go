@@ -0,0 +1,493 @@
+package vault
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Gardego5/gocfg"
+	"github.com/Gardego5/gocfg/utils"
+)
+
+// defaultKubernetesTokenPath is where Kubernetes mounts the service account
+// token used for the Kubernetes auth method.
+const defaultKubernetesTokenPath = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+
+// Option configures the Vault loader
+type Option func(*loader)
+
+// WithAddress sets the Vault server address. Defaults to $VAULT_ADDR.
+func WithAddress(address string) Option {
+	return func(l *loader) { l.address = strings.TrimSuffix(address, "/") }
+}
+
+// WithHTTPClient sets the HTTP client used to talk to Vault
+func WithHTTPClient(client *http.Client) Option {
+	return func(l *loader) { l.httpClient = client }
+}
+
+// WithNamespace sets the Vault Enterprise namespace. Defaults to
+// $VAULT_NAMESPACE.
+func WithNamespace(namespace string) Option {
+	return func(l *loader) { l.namespace = namespace }
+}
+
+// WithToken authenticates with a Vault token. Defaults to $VAULT_TOKEN.
+func WithToken(token string) Option {
+	return func(l *loader) { l.token, l.login = token, nil }
+}
+
+// WithAppRole authenticates with the AppRole auth method mounted at
+// auth/approle.
+func WithAppRole(roleID, secretID string) Option {
+	return func(l *loader) {
+		l.token = ""
+		l.login = func(ctx context.Context) (string, error) {
+			return l.authenticate(ctx, "approle", map[string]string{
+				"role_id":   roleID,
+				"secret_id": secretID,
+			})
+		}
+	}
+}
+
+// WithKubernetes authenticates with the Kubernetes auth method mounted at
+// auth/kubernetes, using the pod's service account token.
+func WithKubernetes(role string) Option {
+	return WithKubernetesToken(role, defaultKubernetesTokenPath)
+}
+
+// WithKubernetesToken authenticates with the Kubernetes auth method using the
+// service account token at tokenPath.
+func WithKubernetesToken(role, tokenPath string) Option {
+	return func(l *loader) {
+		l.token = ""
+		l.login = func(ctx context.Context) (string, error) {
+			jwt, err := os.ReadFile(tokenPath)
+			if err != nil {
+				return "", fmt.Errorf("failed to read service account token: %w", err)
+			}
+
+			return l.authenticate(ctx, "kubernetes", map[string]string{
+				"role": role,
+				"jwt":  strings.TrimSpace(string(jwt)),
+			})
+		}
+	}
+}
+
+// WithLeaseRenewal keeps the leases of dynamic secrets alive by renewing them
+// in the background until ctx is done. Renewal errors are passed to onError,
+// which may be nil.
+func WithLeaseRenewal(ctx context.Context, onError func(error)) Option {
+	return func(l *loader) {
+		l.renewCtx = ctx
+		l.onRenewError = onError
+	}
+}
+
+// VaultLoader loads configuration from HashiCorp Vault KV (v1 and v2) and
+// dynamic secret engines
+func New(opts ...Option) gocfg.Loader {
+	l := &loader{
+		address:    strings.TrimSuffix(os.Getenv("VAULT_ADDR"), "/"),
+		namespace:  os.Getenv("VAULT_NAMESPACE"),
+		token:      os.Getenv("VAULT_TOKEN"),
+		httpClient: http.DefaultClient,
+		secrets:    make(map[string]*secret),
+	}
+
+	for _, opt := range opts {
+		opt(l)
+	}
+
+	return l
+}
+
+type loader struct {
+	address    string
+	namespace  string
+	httpClient *http.Client
+
+	renewCtx     context.Context
+	onRenewError func(error)
+
+	mu      sync.Mutex
+	token   string
+	login   func(ctx context.Context) (string, error)
+	secrets map[string]*secret
+}
+
+// secret is the data read from a secret path, with its lease when the secret
+// is dynamic
+type secret struct {
+	path          string
+	data          map[string]any
+	leaseID       string
+	leaseDuration int
+	renewable     bool
+	expires       time.Time // End of the lease, extended by renewals
+}
+
+// response is the common envelope of Vault API responses
+type response struct {
+	Data          map[string]any `json:"data"`
+	LeaseID       string         `json:"lease_id"`
+	LeaseDuration int            `json:"lease_duration"`
+	Renewable     bool           `json:"renewable"`
+	Auth          *struct {
+		ClientToken string `json:"client_token"`
+	} `json:"auth"`
+	Errors []string `json:"errors"`
+}
+
+func (*loader) GocfgLoaderName() string { return "vault" }
+func (*loader) GocfgEscapedTags() bool  { return true }
+
+// Load implements the Loader interface for HashiCorp Vault
+// Tag formats supported:
+// - "secret/data/app#password" - Get a key from a KV v2 secret
+// - "secret/app#password" - Get a key from a KV v1 secret
+// - "secret/data/app" - Use the field name as the key
+// - "secret/data/app#password?" - Optional secret or key
+// - "database/creds/app#username" - Get a key from a dynamic secret
+// - "secret/data/||@AppName||#password" - Build the path from other fields
+//
+// Secrets with a lease, such as dynamic secrets, are kept by the loader until
+// the lease ends, so fields taking different keys from the same secret receive
+// values from the same lease, across Load calls. Other secrets are read once
+// per batch, and missing secrets are looked up again on every load. A token
+// from AppRole or Kubernetes auth is renewed by logging in again once Vault
+// rejects it.
+func (l *loader) Load(
+	ctx context.Context,
+	field reflect.StructField, value reflect.Value,
+	resolvedTag string,
+) error {
+	ref, err := parseTag(field, resolvedTag)
+	if err != nil {
+		return err
+	}
+
+	sec, err := l.read(ctx, ref.path)
+	return ref.set(value, sec, err)
+}
+
+// LoadBatch implements gocfg.BatchLoader, reading each secret once for all
+// the fields taking keys from it
+func (l *loader) LoadBatch(ctx context.Context, requests []gocfg.FieldRequest) []error {
+	errs := make([]error, len(requests))
+
+	type fetched struct {
+		secret *secret
+		err    error
+	}
+	secrets := make(map[string]fetched)
+
+	for i, request := range requests {
+		ref, err := parseTag(request.Field, request.Tag)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+
+		f, exists := secrets[ref.path]
+		if !exists {
+			f.secret, f.err = l.read(ctx, ref.path)
+			secrets[ref.path] = f
+		}
+
+		errs[i] = ref.set(request.Value, f.secret, f.err)
+	}
+
+	return errs
+}
+
+// secretRef is a parsed tag
+type secretRef struct {
+	path     string
+	key      string
+	optional bool
+}
+
+// parseTag parses the tag of a field
+func parseTag(field reflect.StructField, resolvedTag string) (ref secretRef, err error) {
+	// Handle special case - fully resolved reference or concatenation
+	if strings.HasPrefix(resolvedTag, "@") || strings.Contains(resolvedTag, "||") {
+		// At this point the tag should be resolved already
+		return ref, fmt.Errorf("unexpected unresolved tag: %s", resolvedTag)
+	}
+
+	tag := strings.TrimSpace(resolvedTag)
+
+	// Check if secret is optional
+	tag, ref.optional = utils.CutTagSuffix(tag, "?")
+
+	// Check for key specification, defaulting to the field name
+	secretPath, key, hasKey := utils.CutTag(tag, "#")
+	ref.path = strings.Trim(utils.UnescapeTag(strings.TrimSpace(secretPath)), "/")
+	if !hasKey {
+		key = field.Name
+	}
+	ref.key = utils.UnescapeTag(strings.TrimSpace(key))
+
+	if ref.path == "" {
+		return ref, utils.InvalidTagError("invalid tag %q: expected path#key", resolvedTag)
+	}
+
+	return ref, nil
+}
+
+// set sets a field from the referenced key of a secret, or reports the error
+// reading the secret
+func (ref secretRef) set(value reflect.Value, sec *secret, err error) error {
+	if err != nil {
+		return err
+	}
+
+	if sec == nil {
+		return utils.NotFoundError(ref.optional, "secret %s not found", ref.path)
+	}
+
+	if jsonValue, exists := sec.data[ref.key]; exists {
+		return utils.SetFieldJSONValue(value, jsonValue)
+	}
+
+	return utils.NotFoundError(ref.optional, "key %s not found in secret %s", ref.key, ref.path)
+}
+
+// read returns the secret at path, or nil if it doesn't exist. Secrets with
+// a lease are kept until it ends, others are read from Vault every time.
+func (l *loader) read(ctx context.Context, path string) (*secret, error) {
+	l.mu.Lock()
+	s, isKept := l.secrets[path]
+	isKept = isKept && time.Now().Before(s.expires)
+	l.mu.Unlock()
+	if isKept {
+		return s, nil
+	}
+
+	var resp response
+	status, err := l.do(ctx, http.MethodGet, path, nil, &resp)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read secret %s: %w", path, err)
+	}
+
+	if status == http.StatusNotFound {
+		l.forget(path, s)
+		return nil, nil
+	}
+
+	s = &secret{
+		path:          path,
+		data:          resp.Data,
+		leaseID:       resp.LeaseID,
+		leaseDuration: resp.LeaseDuration,
+		renewable:     resp.Renewable,
+		expires:       time.Now().Add(time.Duration(resp.LeaseDuration) * time.Second),
+	}
+
+	// KV v2 nests the secret's keys under data.data alongside its metadata
+	if data, ok := resp.Data["data"].(map[string]any); ok {
+		if _, ok := resp.Data["metadata"]; ok {
+			s.data = data
+		}
+	}
+
+	if s.leaseDuration <= 0 {
+		l.forget(path, nil)
+		return s, nil
+	}
+
+	l.mu.Lock()
+	l.secrets[path] = s
+	l.mu.Unlock()
+
+	if s.leaseID != "" && s.renewable && l.renewCtx != nil {
+		go l.renew(s)
+	}
+
+	return s, nil
+}
+
+// forget forgets the secret kept for path, unless it was replaced by another
+// than s. A nil s forgets any secret.
+func (l *loader) forget(path string, s *secret) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if kept, exists := l.secrets[path]; exists && (s == nil || kept == s) {
+		delete(l.secrets, path)
+	}
+}
+
+// renew renews the lease of a secret each time two thirds of its duration
+// has passed, extending how long the secret is kept, until the renewal
+// context is done, the lease can no longer be renewed, or the secret was read
+// again.
+func (l *loader) renew(s *secret) {
+	leaseDuration := s.leaseDuration
+	for {
+		wait := time.Duration(leaseDuration) * time.Second * 2 / 3
+		select {
+		case <-l.renewCtx.Done():
+			return
+		case <-time.After(wait):
+		}
+
+		l.mu.Lock()
+		current := l.secrets[s.path] == s
+		l.mu.Unlock()
+		if !current {
+			return
+		}
+
+		var resp response
+		_, err := l.do(l.renewCtx, http.MethodPut, "sys/leases/renew", map[string]any{
+			"lease_id":  s.leaseID,
+			"increment": leaseDuration,
+		}, &resp)
+		if err != nil {
+			if l.renewCtx.Err() != nil {
+				return
+			}
+			if l.onRenewError != nil {
+				l.onRenewError(fmt.Errorf("failed to renew lease %s: %w", s.leaseID, err))
+			}
+			return
+		}
+
+		if resp.LeaseDuration <= 0 {
+			return
+		}
+		leaseDuration = resp.LeaseDuration
+
+		l.mu.Lock()
+		s.expires = time.Now().Add(time.Duration(leaseDuration) * time.Second)
+		l.mu.Unlock()
+
+		if !resp.Renewable {
+			return
+		}
+	}
+}
+
+// authenticate logs in with the auth method mounted at auth/<method> and
+// returns the client token
+func (l *loader) authenticate(ctx context.Context, method string, body map[string]string) (string, error) {
+	var resp response
+	if _, err := l.request(ctx, http.MethodPost, "auth/"+method+"/login", "", body, &resp); err != nil {
+		return "", fmt.Errorf("failed to log in with %s: %w", method, err)
+	}
+	if resp.Auth == nil || resp.Auth.ClientToken == "" {
+		return "", fmt.Errorf("failed to log in with %s: no client token returned", method)
+	}
+
+	return resp.Auth.ClientToken, nil
+}
+
+// do sends an authenticated request to the Vault API, logging in first if the
+// loader has no token yet, and again if Vault rejects the token
+func (l *loader) do(ctx context.Context, method, path string, body, result any) (int, error) {
+	l.mu.Lock()
+	token, login := l.token, l.login
+	l.mu.Unlock()
+
+	if token == "" && login != nil {
+		var err error
+		if token, err = l.logIn(ctx, login); err != nil {
+			return 0, err
+		}
+	}
+
+	status, err := l.request(ctx, method, path, token, body, result)
+	if status == http.StatusForbidden && login != nil {
+		if token, err = l.logIn(ctx, login); err != nil {
+			return 0, err
+		}
+		return l.request(ctx, method, path, token, body, result)
+	}
+
+	return status, err
+}
+
+// logIn logs in and keeps the new token
+func (l *loader) logIn(ctx context.Context, login func(ctx context.Context) (string, error)) (string, error) {
+	token, err := login(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	l.mu.Lock()
+	l.token = token
+	l.mu.Unlock()
+
+	return token, nil
+}
+
+// request sends a request to the Vault API and decodes the response. A 404
+// is returned as a status rather than an error.
+func (l *loader) request(ctx context.Context, method, path, token string, body, result any) (int, error) {
+	if l.address == "" {
+		return 0, errors.New("no Vault address configured")
+	}
+
+	var reader io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return 0, err
+		}
+		reader = bytes.NewReader(encoded)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, l.address+"/v1/"+path, reader)
+	if err != nil {
+		return 0, err
+	}
+	if token != "" {
+		req.Header.Set("X-Vault-Token", token)
+	}
+	if l.namespace != "" {
+		req.Header.Set("X-Vault-Namespace", l.namespace)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := l.httpClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return resp.StatusCode, nil
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		var errResp response
+		_ = json.NewDecoder(resp.Body).Decode(&errResp)
+		if len(errResp.Errors) > 0 {
+			return resp.StatusCode, fmt.Errorf("vault returned %s: %s", resp.Status, strings.Join(errResp.Errors, "; "))
+		}
+		return resp.StatusCode, fmt.Errorf("vault returned %s", resp.Status)
+	}
+
+	if resp.StatusCode != http.StatusNoContent {
+		if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+			return resp.StatusCode, fmt.Errorf("failed to decode response: %w", err)
+		}
+	}
+
+	return resp.StatusCode, nil
+}
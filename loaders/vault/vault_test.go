@@ -0,0 +1,372 @@
+package vault_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/Gardego5/gocfg"
+	"github.com/Gardego5/gocfg/loaders/env"
+	. "github.com/Gardego5/gocfg/loaders/vault"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// MockVault is an in-process stand-in for the parts of the Vault HTTP API
+// used by the loader
+type MockVault struct {
+	Token     string
+	Namespace string
+
+	mu        sync.Mutex
+	Secrets   map[string]map[string]any // More KV v1 secrets by path
+	Reads     map[string]int
+	Renewals  chan string
+	lastLogin map[string]string
+}
+
+func (m *MockVault) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if r.Header.Get("X-Vault-Namespace") != m.Namespace {
+		http.Error(w, `{"errors": ["wrong namespace"]}`, http.StatusForbidden)
+		return
+	}
+
+	// Login endpoints don't require a token
+	switch r.URL.Path {
+	case "/v1/auth/approle/login", "/v1/auth/kubernetes/login":
+		m.lastLogin = map[string]string{}
+		_ = json.NewDecoder(r.Body).Decode(&m.lastLogin)
+		if m.lastLogin["secret_id"] != "secret-id" && m.lastLogin["jwt"] != "service-account-jwt" {
+			http.Error(w, `{"errors": ["permission denied"]}`, http.StatusBadRequest)
+			return
+		}
+		writeJSON(w, map[string]any{"auth": map[string]any{"client_token": m.Token}})
+		return
+	}
+
+	if r.Header.Get("X-Vault-Token") != m.Token {
+		http.Error(w, `{"errors": ["permission denied"]}`, http.StatusForbidden)
+		return
+	}
+
+	if m.Reads == nil {
+		m.Reads = make(map[string]int)
+	}
+	m.Reads[r.URL.Path]++
+
+	switch r.URL.Path {
+	case "/v1/secret/data/app":
+		writeJSON(w, map[string]any{"data": map[string]any{
+			"data":     map[string]any{"password": "v2-password", "port": 5432, "enabled": true},
+			"metadata": map[string]any{"version": 3},
+		}})
+
+	case "/v1/kv/app":
+		writeJSON(w, map[string]any{"data": map[string]any{"password": "v1-password", "Username": "v1-user"}})
+
+	case "/v1/secret/data/testapp":
+		writeJSON(w, map[string]any{"data": map[string]any{
+			"data":     map[string]any{"apiKey": "test-api-key"},
+			"metadata": map[string]any{"version": 1},
+		}})
+
+	case "/v1/database/creds/app":
+		writeJSON(w, map[string]any{
+			"lease_id":       "database/creds/app/lease",
+			"lease_duration": 1,
+			"renewable":      true,
+			"data":           map[string]any{"username": "v-app-user", "password": "v-app-pass"},
+		})
+
+	case "/v1/sys/leases/renew":
+		var body map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		if m.Renewals != nil {
+			select {
+			case m.Renewals <- body["lease_id"].(string):
+			default:
+			}
+		}
+		writeJSON(w, map[string]any{"lease_id": body["lease_id"], "lease_duration": 1, "renewable": true})
+
+	default:
+		if data, exists := m.Secrets[r.URL.Path]; exists {
+			writeJSON(w, map[string]any{"data": data})
+			return
+		}
+		http.Error(w, `{"errors": []}`, http.StatusNotFound)
+	}
+}
+
+func (m *MockVault) Set(path string, data map[string]any) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.Secrets == nil {
+		m.Secrets = make(map[string]map[string]any)
+	}
+	m.Secrets[path] = data
+}
+
+func (m *MockVault) SetToken(token string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.Token = token
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func setupMockVault(t *testing.T) (*MockVault, *httptest.Server) {
+	vault := &MockVault{Token: "root-token"}
+	server := httptest.NewServer(vault)
+	t.Cleanup(server.Close)
+	return vault, server
+}
+
+func TestVaultLoader(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Loads keys from KV v2 secrets", func(t *testing.T) {
+		_, server := setupMockVault(t)
+		loader := New(WithAddress(server.URL), WithToken("root-token"))
+
+		result, err := Load[struct {
+			Password string `vault:"secret/data/app#password"`
+			Port     int    `vault:"secret/data/app#port"`
+			Enabled  bool   `vault:"secret/data/app#enabled"`
+		}](ctx, loader)
+
+		require.NoError(t, err)
+		assert.Equal(t, "v2-password", result.Password)
+		assert.Equal(t, 5432, result.Port)
+		assert.True(t, result.Enabled)
+	})
+
+	t.Run("Loads keys from KV v1 secrets", func(t *testing.T) {
+		_, server := setupMockVault(t)
+		loader := New(WithAddress(server.URL), WithToken("root-token"))
+
+		result, err := Load[struct {
+			Password string `vault:"kv/app#password"`
+		}](ctx, loader)
+
+		require.NoError(t, err)
+		assert.Equal(t, "v1-password", result.Password)
+	})
+
+	t.Run("Uses field name as key when no key specified", func(t *testing.T) {
+		_, server := setupMockVault(t)
+		loader := New(WithAddress(server.URL), WithToken("root-token"))
+
+		result, err := Load[struct {
+			Username string `vault:"kv/app"`
+		}](ctx, loader)
+
+		require.NoError(t, err)
+		assert.Equal(t, "v1-user", result.Username)
+	})
+
+	t.Run("Reads environment defaults", func(t *testing.T) {
+		_, server := setupMockVault(t)
+		t.Setenv("VAULT_ADDR", server.URL)
+		t.Setenv("VAULT_TOKEN", "root-token")
+
+		result, err := Load[struct {
+			Password string `vault:"kv/app#password"`
+		}](ctx, New())
+
+		require.NoError(t, err)
+		assert.Equal(t, "v1-password", result.Password)
+	})
+
+	t.Run("Sends the namespace header", func(t *testing.T) {
+		vault, server := setupMockVault(t)
+		vault.Namespace = "team-a"
+
+		_, err := Load[struct {
+			Password string `vault:"kv/app#password"`
+		}](ctx, New(WithAddress(server.URL), WithToken("root-token")))
+		require.Error(t, err)
+
+		result, err := Load[struct {
+			Password string `vault:"kv/app#password"`
+		}](ctx, New(WithAddress(server.URL), WithToken("root-token"), WithNamespace("team-a")))
+
+		require.NoError(t, err)
+		assert.Equal(t, "v1-password", result.Password)
+	})
+
+	t.Run("Authenticates with AppRole", func(t *testing.T) {
+		_, server := setupMockVault(t)
+		loader := New(WithAddress(server.URL), WithAppRole("role-id", "secret-id"))
+
+		result, err := Load[struct {
+			Password string `vault:"kv/app#password"`
+		}](ctx, loader)
+
+		require.NoError(t, err)
+		assert.Equal(t, "v1-password", result.Password)
+
+		_, err = Load[struct {
+			Password string `vault:"kv/app#password"`
+		}](ctx, New(WithAddress(server.URL), WithAppRole("role-id", "wrong")))
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "permission denied")
+	})
+
+	t.Run("Authenticates with Kubernetes service account tokens", func(t *testing.T) {
+		_, server := setupMockVault(t)
+
+		tokenPath := filepath.Join(t.TempDir(), "token")
+		require.NoError(t, os.WriteFile(tokenPath, []byte("service-account-jwt\n"), 0o600))
+
+		result, err := Load[struct {
+			Password string `vault:"kv/app#password"`
+		}](ctx, New(WithAddress(server.URL), WithKubernetesToken("app", tokenPath)))
+
+		require.NoError(t, err)
+		assert.Equal(t, "v1-password", result.Password)
+	})
+
+	t.Run("Handles optional secrets and keys", func(t *testing.T) {
+		_, server := setupMockVault(t)
+		loader := New(WithAddress(server.URL), WithToken("root-token"))
+
+		result, err := Load[struct {
+			Secret string `vault:"secret/data/missing#password?"`
+			Key    string `vault:"secret/data/app#missing?"`
+		}](ctx, loader)
+
+		require.NoError(t, err)
+		assert.Empty(t, result.Secret)
+		assert.Empty(t, result.Key)
+	})
+
+	t.Run("Errors on missing required secrets and keys", func(t *testing.T) {
+		_, server := setupMockVault(t)
+		loader := New(WithAddress(server.URL), WithToken("root-token"))
+
+		_, err := Load[struct {
+			Value string `vault:"secret/data/missing#password"`
+		}](ctx, loader)
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "secret/data/missing")
+
+		_, err = Load[struct {
+			Value string `vault:"secret/data/app#missing"`
+		}](ctx, loader)
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "missing")
+	})
+
+	t.Run("Handles references to other fields", func(t *testing.T) {
+		_, server := setupMockVault(t)
+		t.Setenv("APP_NAME", "testapp")
+
+		result, err := Load[struct {
+			AppName string `env:"APP_NAME"`
+			ApiKey  string `vault:"secret/data/||@AppName||#apiKey"`
+		}](ctx, env.New(), New(WithAddress(server.URL), WithToken("root-token")))
+
+		require.NoError(t, err)
+		assert.Equal(t, "test-api-key", result.ApiKey)
+	})
+
+	t.Run("Reads dynamic secrets once per lease", func(t *testing.T) {
+		vault, server := setupMockVault(t)
+		loader := New(WithAddress(server.URL), WithToken("root-token"))
+
+		type config struct {
+			Username string `vault:"database/creds/app#username"`
+			Password string `vault:"database/creds/app#password"`
+		}
+
+		result, err := Load[config](ctx, loader)
+		require.NoError(t, err)
+		assert.Equal(t, "v-app-user", result.Username)
+		assert.Equal(t, "v-app-pass", result.Password)
+
+		_, err = Load[config](ctx, loader)
+		require.NoError(t, err)
+		assert.Equal(t, 1, vault.Reads["/v1/database/creds/app"])
+
+		// The mock's leases last a second
+		time.Sleep(1100 * time.Millisecond)
+		_, err = Load[config](ctx, loader)
+		require.NoError(t, err)
+		assert.Equal(t, 2, vault.Reads["/v1/database/creds/app"])
+	})
+
+	t.Run("Looks up missing secrets again on later loads", func(t *testing.T) {
+		vault, server := setupMockVault(t)
+		loader := New(WithAddress(server.URL), WithToken("root-token"))
+
+		type config struct {
+			Flag string `vault:"kv/flags#beta?"`
+		}
+
+		result, err := Load[config](ctx, loader)
+		require.NoError(t, err)
+		assert.Empty(t, result.Flag)
+
+		vault.Set("/v1/kv/flags", map[string]any{"beta": "on"})
+		result, err = Load[config](ctx, loader)
+		require.NoError(t, err)
+		assert.Equal(t, "on", result.Flag)
+	})
+
+	t.Run("Logs in again when the token is rejected", func(t *testing.T) {
+		vault, server := setupMockVault(t)
+		loader := New(WithAddress(server.URL), WithAppRole("role-id", "secret-id"))
+
+		type config struct {
+			Password string `vault:"kv/app#password"`
+		}
+
+		_, err := Load[config](ctx, loader)
+		require.NoError(t, err)
+
+		vault.SetToken("rotated-token")
+		result, err := Load[config](ctx, loader)
+		require.NoError(t, err)
+		assert.Equal(t, "v1-password", result.Password)
+	})
+
+	t.Run("Renews leases of dynamic secrets", func(t *testing.T) {
+		vault, server := setupMockVault(t)
+		vault.Renewals = make(chan string, 1)
+
+		renewCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		_, err := Load[struct {
+			Username string `vault:"database/creds/app#username"`
+		}](ctx, New(
+			WithAddress(server.URL),
+			WithToken("root-token"),
+			WithLeaseRenewal(renewCtx, func(err error) { t.Error(err) }),
+		))
+		require.NoError(t, err)
+
+		select {
+		case leaseID := <-vault.Renewals:
+			assert.Equal(t, "database/creds/app/lease", leaseID)
+		case <-time.After(5 * time.Second):
+			t.Fatal("expected lease to be renewed")
+		}
+	})
+}
@@ -7,20 +7,25 @@ import (
 	"github.com/Gardego5/gocfg"
 )
 
-type withTag[T gocfg.Loader] struct {
-	name   string
-	loader T
+type withTag struct {
+	wrapper
+	name string
 }
 
+// WithTag renames the tag a loader reads. The optional interfaces of the
+// loader, such as gocfg.UntaggedLoader, keep working through the new name.
 func WithTag[T gocfg.Loader](name string, loader T) gocfg.Loader {
-	return &withTag[T]{name: name, loader: loader}
+	w := &withTag{wrapper: wrapper{loader}, name: name}
+	return forward(w, loader, func(ctx context.Context, requests []gocfg.FieldRequest) []error {
+		return w.inner.(gocfg.BatchLoader).LoadBatch(ctx, requests)
+	})
 }
 
-func (w *withTag[T]) GocfgLoaderName() string { return w.name }
-func (w *withTag[T]) Load(
+func (w *withTag) GocfgLoaderName() string { return w.name }
+func (w *withTag) Load(
 	ctx context.Context,
 	field reflect.StructField, value reflect.Value,
 	resolvedTag string,
 ) error {
-	return w.loader.Load(ctx, field, value, resolvedTag)
+	return w.inner.Load(ctx, field, value, resolvedTag)
 }
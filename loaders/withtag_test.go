@@ -8,6 +8,8 @@ import (
 
 	"github.com/Gardego5/gocfg"
 	"github.com/Gardego5/gocfg/loaders"
+	"github.com/Gardego5/gocfg/loaders/env"
+	"github.com/Gardego5/gocfg/loaders/tmpl"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -161,4 +163,23 @@ func TestWithTag(t *testing.T) {
 		assert.Equal(t, "ONE-test1", result.Value1)
 		assert.Equal(t, "TWO-test2", result.Value2)
 	})
+
+	t.Run("Forwards optional loader interfaces", func(t *testing.T) {
+		t.Setenv("WITHTAG_HOST", "db.internal")
+
+		type TestConfig struct {
+			URL  string `template:"postgres://{{.Host}}/app"`
+			Host string `environment:"WITHTAG_HOST"`
+		}
+
+		result, err := gocfg.Load[TestConfig](context.Background(),
+			loaders.WithTag("template", tmpl.New()),
+			loaders.WithTag("environment", env.New()),
+		)
+		require.NoError(t, err)
+		assert.Equal(t, "postgres://db.internal/app", result.URL)
+
+		_, isDeclarer := loaders.WithTag("custom", &MockLoader{}).(gocfg.DependencyDeclarer)
+		assert.False(t, isDeclarer)
+	})
 }
@@ -0,0 +1,44 @@
+package gocfg
+
+import (
+	"context"
+	"time"
+)
+
+// Origin is where the value of a field came from
+type Origin struct {
+	Tag      string    // Tag supplying the value: a loader's name, "default" or "cfg"
+	Cached   bool      // Served from a cache rather than the loader's backend
+	CachedAt time.Time // When a cached value was loaded from the backend
+}
+
+// Provenance is the origin of the value of each field set by Load, keyed by
+// dotted path
+type Provenance map[string]Origin
+
+type originKey struct{}
+
+// MarkCached records in the provenance of the field being loaded that its
+// value comes from a cache, and was loaded from the backend at cachedAt. ctx is
+// the one passed to Load, or FieldRequest.Context in batches. It does nothing
+// when ctx does not come from Load.
+func MarkCached(ctx context.Context, cachedAt time.Time) {
+	if origin, _ := ctx.Value(originKey{}).(*Origin); origin != nil {
+		origin.Cached, origin.CachedAt = true, cachedAt
+	}
+}
+
+// DetachProvenance returns a context like ctx for loading a field in the
+// background, in which MarkCached is recorded apart from the field's
+// provenance, and a function keeping what was recorded once the value loaded
+// is kept. Middleware abandoning slow loads, such as loaders.WithTimeout, uses
+// it so loads finishing late can't change the provenance.
+func DetachProvenance(ctx context.Context) (detached context.Context, keep func()) {
+	origin, _ := ctx.Value(originKey{}).(*Origin)
+	if origin == nil {
+		return ctx, func() {}
+	}
+
+	scratch := *origin
+	return context.WithValue(ctx, originKey{}, &scratch), func() { *origin = scratch }
+}
@@ -1,41 +1,215 @@
 package gocfg
 
 import (
+	"encoding"
+	"encoding/base64"
 	"fmt"
+	"net/url"
 	"reflect"
 	"strings"
 
 	"github.com/Gardego5/gocfg/utils"
 )
 
-// parseTag parses a tag and extracts field references
-func parseTag(tag string) (references []string, err error) {
-	inEscape := false
+// The tag mini-language, before loaders see it:
+//   - @Field or @Parent.Field - The value of another field
+//   - @Field:%05d - The value formatted with a fmt verb
+//   - @Field|upper - The value passed through filters, see referenceFilters
+//   - a || b - Concatenation, ignoring whitespace around the ||
+//   - "c - The character c, literally, as in "@ or "|
+//   - 'text' - Quoted text, literally; "' is a quote inside it. A ' after a
+//     letter or digit is an apostrophe, as in it's, not a quote.
+//
+// Anything else, including a single |, is passed to the loader as is.
+// Literal metacharacters are passed escaped to loaders implementing
+// EscapedTagLoader, see utils.TagMetachars, and unescaped to others.
 
-	for i := 0; i < len(tag); i++ {
-		c := tag[i]
+// SyntaxError reports a malformed tag, with the byte offset of the problem
+type SyntaxError struct {
+	Tag    string
+	Offset int
+	Msg    string
+}
 
-		if inEscape {
-			inEscape = false
-			continue
-		}
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("syntax error in tag %q at offset %d: %s", e.Tag, e.Offset, e.Msg)
+}
+
+type tokenKind int
+
+const (
+	literalToken   tokenKind = iota // Text for the loader, escaped as needed
+	referenceToken                  // @Field, with the field path as text
+	concatToken                     // ||
+)
+
+type token struct {
+	kind    tokenKind
+	text    string
+	offset  int
+	format  string   // fmt verb of a reference, if any
+	filters []string // Filters of a reference, in order
+}
+
+// referenceFilters transform referenced values, as in @Env|upper
+var referenceFilters = map[string]func(string) string{
+	"upper":    strings.ToUpper,
+	"lower":    strings.ToLower,
+	"trim":     strings.TrimSpace,
+	"base64":   func(s string) string { return base64.StdEncoding.EncodeToString([]byte(s)) },
+	"urlquery": url.QueryEscape,
+}
+
+// formatVerbEnd returns the end of the fmt verb starting at tag[i], as in
+// %05d or %.2f, or -1 if there is none
+func formatVerbEnd(tag string, i int) int {
+	if i >= len(tag) || tag[i] != '%' {
+		return -1
+	}
+	i++
+	for i < len(tag) && strings.IndexByte("+-# 0123456789.", tag[i]) >= 0 {
+		i++
+	}
+	if i < len(tag) && ((tag[i] >= 'a' && tag[i] <= 'z') || (tag[i] >= 'A' && tag[i] <= 'Z')) {
+		return i + 1
+	}
+	return -1
+}
 
-		if c == '"' {
-			inEscape = true
-			continue
+// tokenize splits a tag into literals, references and concatenations
+func tokenize(tag string) ([]token, error) {
+	var tokens []token
+	var literal strings.Builder
+	literalStart := -1
+
+	syntaxError := func(offset int, format string, args ...any) error {
+		return &SyntaxError{Tag: tag, Offset: offset, Msg: fmt.Sprintf(format, args...)}
+	}
+	appendLiteral := func(offset int, text string) {
+		if literalStart < 0 {
+			literalStart = offset
 		}
+		literal.WriteString(text)
+	}
+	flushLiteral := func() {
+		if literalStart >= 0 {
+			tokens = append(tokens, token{kind: literalToken, text: literal.String(), offset: literalStart})
+			literal.Reset()
+			literalStart = -1
+		}
+	}
+
+	for i := 0; i < len(tag); i++ {
+		switch c := tag[i]; {
+		case c == utils.TagEscape:
+			if i+1 >= len(tag) {
+				return nil, syntaxError(i, "escape at end of tag")
+			}
+			appendLiteral(i, string([]byte{utils.TagEscape, tag[i+1]}))
+			i++
 
-		if c == '@' && i+1 < len(tag) && isIdentChar(tag[i+1]) {
-			// Extract field reference
-			start := i + 1
-			end := start
-			for end < len(tag) && isIdentChar(tag[end]) {
+		case c == '\'' && (i == 0 || !isIdentChar(tag[i-1])):
+			var quoted strings.Builder
+			end := -1
+			for j := i + 1; j < len(tag) && end < 0; j++ {
+				switch {
+				case tag[j] == utils.TagEscape && j+1 < len(tag):
+					j++
+					quoted.WriteByte(tag[j])
+				case tag[j] == '\'':
+					end = j
+				default:
+					quoted.WriteByte(tag[j])
+				}
+			}
+			if end < 0 {
+				return nil, syntaxError(i, "unterminated quote")
+			}
+			appendLiteral(i, utils.EscapeTag(quoted.String()))
+			i = end
+
+		case c == '@':
+			if i+1 >= len(tag) || !isIdentChar(tag[i+1]) {
+				return nil, syntaxError(i, "expected a field name after @, or \"@ for a literal @")
+			}
+			end := i + 1
+			for end < len(tag) && isPathChar(tag, end) {
 				end++
 			}
+			ref := token{kind: referenceToken, text: tag[i+1 : end], offset: i}
 
-			fieldName := tag[start:end]
-			references = append(references, fieldName)
+			// Format verb, as in @Port:%05d
+			if end+1 < len(tag) && tag[end] == ':' && tag[end+1] == '%' {
+				verbEnd := formatVerbEnd(tag, end+1)
+				if verbEnd < 0 {
+					return nil, syntaxError(end+1, "invalid format verb")
+				}
+				ref.format = tag[end+1 : verbEnd]
+				end = verbEnd
+			}
+
+			// Filters, as in @Env|upper|trim
+			for end+1 < len(tag) && tag[end] == '|' && isIdentChar(tag[end+1]) {
+				start := end + 1
+				for end = start; end < len(tag) && isIdentChar(tag[end]); {
+					end++
+				}
+				filter := tag[start:end]
+				if _, exists := referenceFilters[filter]; !exists {
+					return nil, syntaxError(start, "unknown filter %q", filter)
+				}
+				ref.filters = append(ref.filters, filter)
+			}
+
+			flushLiteral()
+			tokens = append(tokens, ref)
 			i = end - 1
+
+		case c == '|' && i+1 < len(tag) && tag[i+1] == '|':
+			if literalStart >= 0 {
+				// Whitespace before || is not part of the operand
+				trimmed := strings.TrimRight(literal.String(), " \t")
+				literal.Reset()
+				literal.WriteString(trimmed)
+				if trimmed == "" {
+					literalStart = -1
+				}
+			}
+			flushLiteral()
+			if len(tokens) == 0 || tokens[len(tokens)-1].kind == concatToken {
+				return nil, syntaxError(i, "missing operand before ||")
+			}
+			tokens = append(tokens, token{kind: concatToken, offset: i})
+			i++
+
+			// Neither is whitespace after it
+			for i+1 < len(tag) && (tag[i+1] == ' ' || tag[i+1] == '\t') {
+				i++
+			}
+
+		default:
+			appendLiteral(i, string(c))
+		}
+	}
+	flushLiteral()
+
+	if n := len(tokens); n > 0 && tokens[n-1].kind == concatToken {
+		return nil, syntaxError(tokens[n-1].offset, "missing operand after ||")
+	}
+
+	return tokens, nil
+}
+
+// parseTag parses a tag and extracts field references
+func parseTag(tag string) (references []string, err error) {
+	tokens, err := tokenize(tag)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, t := range tokens {
+		if t.kind == referenceToken {
+			references = append(references, t.text)
 		}
 	}
 
@@ -50,59 +224,117 @@ func isIdentChar(c byte) bool {
 		c == '_'
 }
 
-// resolveTag resolves all references in a tag using current field values
-func resolveTag(tag string, configValue reflect.Value) (string, error) {
-	// Handle concatenation with ||
-	if strings.Contains(tag, "||") {
-		parts := strings.Split(tag, "||")
-		var resolvedParts []string
-
-		for _, part := range parts {
-			part = strings.TrimSpace(part)
-			resolved, err := resolvePart(part, configValue)
-			if err != nil {
-				return "", err
-			}
-			resolvedParts = append(resolvedParts, resolved)
+// isPathChar returns true if tag[i] continues a dotted field path: an
+// identifier character, or a dot followed by one
+func isPathChar(tag string, i int) bool {
+	return isIdentChar(tag[i]) ||
+		(tag[i] == '.' && i+1 < len(tag) && isIdentChar(tag[i+1]))
+}
+
+// lookupField returns the field at a dotted path in the config struct
+func lookupField(configValue reflect.Value, fieldPath string) reflect.Value {
+	field := configValue
+	for _, name := range strings.Split(fieldPath, ".") {
+		if field.Kind() != reflect.Struct {
+			return reflect.Value{}
+		}
+		if field = field.FieldByName(name); !field.IsValid() {
+			return reflect.Value{}
 		}
+	}
+	return field
+}
 
-		return strings.Join(resolvedParts, ""), nil
+// formatField formats the value of a field as text, the way it would be
+// loaded: text marshalers marshal themselves, and other values are printed
+// with fmt. Nil pointers format as "".
+func formatField(field reflect.Value) string {
+	for field.Kind() == reflect.Pointer {
+		if field.IsNil() {
+			return ""
+		}
+		field = field.Elem()
 	}
 
-	return resolvePart(tag, configValue)
+	value := field.Interface()
+	if field.CanAddr() {
+		value = field.Addr().Interface() // Pointer receivers
+	}
+	if marshaler, ok := value.(encoding.TextMarshaler); ok {
+		if text, err := marshaler.MarshalText(); err == nil {
+			return string(text)
+		}
+	}
+
+	if field.Kind() == reflect.String {
+		return field.String()
+	}
+	return fmt.Sprint(field.Interface())
 }
 
-// resolvePart resolves a single part of a tag (handling @Field and escape sequences)
-func resolvePart(part string, configValue reflect.Value) (string, error) {
-	if strings.HasPrefix(part, "@") {
-		fieldName := part[1:]
-		field := configValue.FieldByName(fieldName)
-		if !field.IsValid() {
-			return "", fmt.Errorf("%w: %s", utils.ErrUnboundVariable, fieldName)
+// formatReference formats a referenced field with the reference's format
+// verb and filters. Verbs apply to the value a pointer field points to, and
+// nil pointers format as "" like with formatField.
+func formatReference(ref token, field reflect.Value) string {
+	var value string
+	if ref.format != "" {
+		for field.Kind() == reflect.Pointer && !field.IsNil() {
+			field = field.Elem()
 		}
-		return field.String(), nil
+		if field.Kind() != reflect.Pointer {
+			value = fmt.Sprintf(ref.format, field.Interface())
+		}
+	} else {
+		value = formatField(field)
 	}
 
-	// Handle escape sequences
-	var result strings.Builder
-	inEscape := false
+	for _, filter := range ref.filters {
+		value = referenceFilters[filter](value)
+	}
 
-	for i := 0; i < len(part); i++ {
-		c := part[i]
+	return value
+}
 
-		if inEscape {
-			result.WriteByte(c)
-			inEscape = false
-			continue
-		}
+// resolveTag resolves all references in a tag using current field values.
+// Referenced values are escaped, so loaders read them literally.
+func resolveTag(tag string, configValue reflect.Value) (string, error) {
+	tokens, err := tokenize(tag)
+	if err != nil {
+		return "", err
+	}
+
+	var resolved strings.Builder
+	for _, t := range tokens {
+		switch t.kind {
+		case literalToken:
+			resolved.WriteString(t.text)
 
-		if c == '"' {
-			inEscape = true
-			continue
+		case referenceToken:
+			field := lookupField(configValue, t.text)
+			if !field.IsValid() {
+				return "", fmt.Errorf("%w: %s", utils.ErrUnboundVariable, t.text)
+			}
+			resolved.WriteString(utils.EscapeTag(formatReference(t, field)))
 		}
+	}
 
-		result.WriteByte(c)
+	return resolved.String(), nil
+}
+
+// resolve resolves the tag of a source the way its loader reads it: as
+// written when raw, escaped for an EscapedTagLoader, and unescaped otherwise
+func (src source) resolve(configValue reflect.Value) (string, error) {
+	if src.raw {
+		return src.tag, nil
+	}
+
+	resolved, err := resolveTag(src.tag, configValue)
+	if err != nil {
+		return "", err
 	}
 
-	return result.String(), nil
+	if escaped, ok := src.loader.(EscapedTagLoader); ok && escaped.GocfgEscapedTags() {
+		return resolved, nil
+	}
+	return utils.UnescapeTag(resolved), nil
 }
@@ -0,0 +1,86 @@
+package gocfg
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Gardego5/gocfg/utils"
+)
+
+func FuzzTokenize(f *testing.F) {
+	for _, seed := range []string{
+		`VAR`, `VAR?`, `VAR=default`, `@A||@B`, `@Database.Host || :5432`,
+		`'a||b'`, `"@"@SPECIAL`, `@Port:%05d`, `@Env|upper|trim`, `@Env|bogus`, `'it"'s'`, `VAR=it's`, `VAR='`, `a|b`, `||`, `@`, `"`,
+	} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, tag string) {
+		tokens, err := tokenize(tag)
+		if err != nil {
+			if _, ok := err.(*SyntaxError); !ok {
+				t.Fatalf("expected *SyntaxError, got %T: %s", err, err)
+			}
+			return
+		}
+
+		last := -1
+		for _, tok := range tokens {
+			if tok.offset <= last || tok.offset >= len(tag) {
+				t.Fatalf("token offset %d out of order in %q", tok.offset, tag)
+			}
+			last = tok.offset
+
+			if tok.kind == concatToken && tok.text != "" {
+				t.Fatalf("concatenation with text %q in %q", tok.text, tag)
+			}
+		}
+	})
+}
+
+func FuzzQuotedLiteral(f *testing.F) {
+	for _, seed := range []string{"", "plain", "a||b", `it's "quoted"`, "@Field", "?=:,#;"} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, literal string) {
+		// Quote the literal, escaping quotes and escapes within it
+		var tag strings.Builder
+		tag.WriteByte('\'')
+		for i := 0; i < len(literal); i++ {
+			if literal[i] == '\'' || literal[i] == utils.TagEscape {
+				tag.WriteByte(utils.TagEscape)
+			}
+			tag.WriteByte(literal[i])
+		}
+		tag.WriteByte('\'')
+
+		resolved, err := resolveTag(tag.String(), reflect.ValueOf(struct{}{}))
+		if err != nil {
+			t.Fatalf("unexpected error for %q: %s", tag.String(), err)
+		}
+		if unescaped := utils.UnescapeTag(resolved); unescaped != literal {
+			t.Fatalf("expected %q to resolve to %q, got %q", tag.String(), literal, unescaped)
+		}
+	})
+}
+
+func FuzzParseExpr(f *testing.F) {
+	for _, seed := range []string{
+		`@Host + ':' + str(@Port)`, `@Replicas * 2`, `coalesce(@A, @B, 'x')`, `lower(@Env)`,
+		`if(1 < 2, 'a', 1 / 0)`, `-(-9223372036854775807 - 1) / -1`, `'unterminated`, `((1)`, `max()`,
+	} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, src string) {
+		e, _, err := parseExpr(src)
+		if err != nil {
+			return
+		}
+
+		// Evaluating must fail or succeed without panicking
+		_, _ = e.eval(reflect.ValueOf(struct{}{}))
+	})
+}
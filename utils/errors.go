@@ -1,6 +1,9 @@
 package utils
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+)
 
 var (
 	// ErrCircularDependency is returned when a circular dependency is detected
@@ -22,4 +25,52 @@ var (
 
 	// ErrMissingRequired is returned when a required field is not set.
 	ErrMissingRequired = errors.New("required value not set")
+
+	// ErrNotFound is returned by a loader when its source has no value for a
+	// field. gocfg.Load then tries the field's next loader, and leaves the
+	// field unset if none of them has a value.
+	ErrNotFound = errors.New("value not found")
+
+	// ErrConflictingSources is returned when a value is provided by more than
+	// one source and it is unclear which should be used.
+	ErrConflictingSources = errors.New("value provided by conflicting sources")
+
+	// ErrUnknownVariable is returned in strict mode when a source has values
+	// that no field consumed, usually because of a typo in their name.
+	ErrUnknownVariable = errors.New("unknown variable")
+
+	// ErrInvalidTag is returned by a loader when a tag doesn't follow its
+	// syntax, as with InvalidTagError.
+	ErrInvalidTag = errors.New("invalid tag")
+
+	// ErrInvalidValue is returned by SetFieldValue when a value can't be set on
+	// a field, such as text that doesn't parse as the field's type.
+	ErrInvalidValue = errors.New("invalid value")
 )
+
+// NotFoundError reports that a source has no value for a field. The error
+// wraps ErrNotFound for optional fields and ErrMissingRequired otherwise, so
+// gocfg.Load can fall back to the field's next loader or default either way.
+func NotFoundError(optional bool, format string, args ...any) error {
+	sentinel := ErrMissingRequired
+	if optional {
+		sentinel = ErrNotFound
+	}
+	return fmt.Errorf("%w: "+format, append([]any{sentinel}, args...)...)
+}
+
+// InvalidTagError reports a tag not following a loader's syntax. The error
+// wraps ErrInvalidTag, and the errors of any %w verbs, with the message
+// formatted as is.
+func InvalidTagError(format string, args ...any) error {
+	return &sentinelError{sentinel: ErrInvalidTag, err: fmt.Errorf(format, args...)}
+}
+
+// sentinelError wraps a sentinel error without repeating it in the message
+type sentinelError struct {
+	sentinel error
+	err      error
+}
+
+func (e *sentinelError) Error() string   { return e.err.Error() }
+func (e *sentinelError) Unwrap() []error { return []error{e.sentinel, e.err} }
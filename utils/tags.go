@@ -0,0 +1,127 @@
+package utils
+
+import "strings"
+
+// TagEscape is the character escaping the character after it in a tag, as in
+// "@ for a literal @.
+const TagEscape = '"'
+
+// TagMetachars are the characters with a meaning in tags. gocfg.Load passes
+// them to loaders implementing gocfg.EscapedTagLoader escaped with TagEscape
+// when they are meant literally: when escaped or quoted in the tag, or part of
+// a referenced field's value. The / separating paths, as in the item keys of
+// aws/dynamodb, is escaped too. Other escaped characters keep their escape,
+// so loaders with syntax of their own can tell them apart.
+const TagMetachars = `"'@|?=:,#;/`
+
+// EscapeTag escapes the metacharacters in s, so loaders read it literally
+func EscapeTag(s string) string {
+	if !strings.ContainsAny(s, TagMetachars) {
+		return s
+	}
+
+	var escaped strings.Builder
+	for i := 0; i < len(s); i++ {
+		if strings.IndexByte(TagMetachars, s[i]) >= 0 {
+			escaped.WriteByte(TagEscape)
+		}
+		escaped.WriteByte(s[i])
+	}
+	return escaped.String()
+}
+
+// UnescapeTag removes the escapes from a piece of a resolved tag. Loaders call
+// it on each piece once they have split the tag on its unescaped syntax.
+func UnescapeTag(s string) string {
+	if strings.IndexByte(s, TagEscape) < 0 {
+		return s
+	}
+
+	var unescaped strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == TagEscape && i+1 < len(s) {
+			i++
+		}
+		unescaped.WriteByte(s[i])
+	}
+	return unescaped.String()
+}
+
+// IndexTag returns the index of the first unescaped sep in s, or -1
+func IndexTag(s, sep string) int {
+	for i := 0; i < len(s); i++ {
+		if s[i] == TagEscape {
+			i++
+			continue
+		}
+		if strings.HasPrefix(s[i:], sep) {
+			return i
+		}
+	}
+	return -1
+}
+
+// LastIndexTag returns the index of the last unescaped sep in s, or -1
+func LastIndexTag(s, sep string) int {
+	last := -1
+	for i := 0; i < len(s); i++ {
+		if s[i] == TagEscape {
+			i++
+			continue
+		}
+		if strings.HasPrefix(s[i:], sep) {
+			last = i
+		}
+	}
+	return last
+}
+
+// CutTag is strings.Cut for resolved tags, cutting around the first
+// unescaped sep
+func CutTag(s, sep string) (before, after string, found bool) {
+	if i := IndexTag(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+	return s, "", false
+}
+
+// CutTagLast cuts s around the last unescaped sep
+func CutTagLast(s, sep string) (before, after string, found bool) {
+	if i := LastIndexTag(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+	return s, "", false
+}
+
+// SplitTag is strings.Split for resolved tags, splitting on unescaped sep
+func SplitTag(s, sep string) []string {
+	var parts []string
+	for {
+		before, after, found := CutTag(s, sep)
+		parts = append(parts, before)
+		if !found {
+			return parts
+		}
+		s = after
+	}
+}
+
+// CutTagSuffix is strings.CutSuffix for resolved tags, only removing an
+// unescaped suffix
+func CutTagSuffix(s, suffix string) (before string, found bool) {
+	if !strings.HasSuffix(s, suffix) {
+		return s, false
+	}
+
+	// The suffix is escaped when an odd number of escapes precede it
+	start := len(s) - len(suffix)
+	escapes := 0
+	for i := start - 1; i >= 0 && s[i] == TagEscape; i-- {
+		escapes++
+	}
+	if escapes%2 == 1 {
+		return s, false
+	}
+
+	return s[:start], true
+}
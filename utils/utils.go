@@ -7,10 +7,19 @@ import (
 	"fmt"
 	"reflect"
 	"strconv"
+	"time"
 )
 
-// SetFieldValue sets the appropriate value on the field based on its type
+// SetFieldValue sets the appropriate value on the field based on its type.
+// Errors wrap ErrInvalidValue.
 func SetFieldValue(fieldValue reflect.Value, value string) error {
+	if err := setFieldValue(fieldValue, value); err != nil {
+		return &sentinelError{sentinel: ErrInvalidValue, err: err}
+	}
+	return nil
+}
+
+func setFieldValue(fieldValue reflect.Value, value string) error {
 
 	switch val := fieldValue.Addr().Interface().(type) {
 
@@ -29,7 +38,20 @@ func SetFieldValue(fieldValue reflect.Value, value string) error {
 		case reflect.String:
 			fieldValue.SetString(value)
 
-		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		case reflect.Int64:
+			if fieldValue.Type() == reflect.TypeFor[time.Duration]() {
+				// Accept "1m30s" as well as plain nanoseconds
+				if duration, err := time.ParseDuration(value); err == nil {
+					fieldValue.SetInt(int64(duration))
+					break
+				}
+				if _, err := strconv.ParseInt(value, 10, 64); err != nil {
+					return fmt.Errorf("invalid duration value: %s", value)
+				}
+			}
+			fallthrough
+
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32:
 			intValue, err := strconv.ParseInt(value, 10, 64)
 			if err != nil {
 				return fmt.Errorf("invalid integer value: %s", value)
@@ -72,3 +94,57 @@ func SetFieldValue(fieldValue reflect.Value, value string) error {
 
 	return nil
 }
+
+// SetFieldJSONValue sets the field from a value decoded from a JSON, YAML or
+// TOML document. Scalars are converted to text and set with SetFieldValue.
+// Objects and arrays are decoded into struct, map, slice and array fields,
+// and re-encoded as JSON text for any other field. Errors wrap
+// ErrInvalidValue.
+func SetFieldJSONValue(fieldValue reflect.Value, jsonValue any) error {
+	var stringValue string
+	switch v := jsonValue.(type) {
+	case string:
+		stringValue = v
+	case json.Number:
+		stringValue = v.String()
+	case float64:
+		if v == float64(int(v)) {
+			stringValue = fmt.Sprintf("%.0f", v)
+		} else {
+			stringValue = fmt.Sprintf("%g", v)
+		}
+	case int:
+		stringValue = strconv.Itoa(v)
+	case int64:
+		stringValue = strconv.FormatInt(v, 10)
+	case uint64:
+		stringValue = strconv.FormatUint(v, 10)
+	case time.Time:
+		stringValue = v.Format(time.RFC3339Nano)
+	case bool:
+		stringValue = fmt.Sprintf("%t", v)
+	case nil:
+		stringValue = ""
+	default:
+		// For complex types, re-encode as JSON
+		bytes, err := json.Marshal(v)
+		if err != nil {
+			return &sentinelError{sentinel: ErrInvalidValue, err: fmt.Errorf("failed to marshal complex value: %w", err)}
+		}
+
+		switch fieldValue.Addr().Interface().(type) {
+		case encoding.TextUnmarshaler, encoding.BinaryUnmarshaler, json.Unmarshaler:
+		default:
+			switch fieldValue.Kind() {
+			case reflect.Struct, reflect.Map, reflect.Slice, reflect.Array:
+				if err := json.Unmarshal(bytes, fieldValue.Addr().Interface()); err != nil {
+					return &sentinelError{sentinel: ErrInvalidValue, err: err}
+				}
+				return nil
+			}
+		}
+		stringValue = string(bytes)
+	}
+
+	return SetFieldValue(fieldValue, stringValue)
+}